@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/subcommands"
+	"github.com/micro-eshop/catalog/internal/postgres"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const defaultImportStatusLimit = 10
+
+type ImportStatusCmd struct {
+	postgresConn string
+	limit        int
+	v            *viper.Viper
+}
+
+func NewImportStatusCmd(v *viper.Viper) *ImportStatusCmd {
+	return &ImportStatusCmd{v: v}
+}
+
+func (*ImportStatusCmd) Name() string     { return "import-status" }
+func (*ImportStatusCmd) Synopsis() string { return "List recent product imports" }
+func (*ImportStatusCmd) Usage() string {
+	return ""
+}
+
+func (p *ImportStatusCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&p.postgresConn, "postgresConn", p.v.GetString("POSTGRES_CONNECTION"), "postgresConn connection string")
+	f.IntVar(&p.limit, "limit", defaultImportStatusLimit, "number of runs to list")
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+func printImportRuns(w io.Writer, runs []*repositories.ImportRun) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tSTATUS\tOFFSET\tRECORDS\tCREATED\tRESTORED\tUPDATED\tUNCHANGED\tINVALID\tFAILED\tARCHIVED\tSTARTED\tFINISHED\tSOURCE\tERROR")
+	for _, run := range runs {
+		fmt.Fprintf(table, "%d\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%s\t%s\t%s\n", run.ID, run.Status, run.Offset, run.Records,
+			run.Created, run.Restored, run.Updated, run.Unchanged, run.Invalid, run.Failed, run.Archived,
+			formatTime(&run.StartedAt), formatTime(run.FinishedAt), run.SourcePath, run.Error)
+	}
+	return table.Flush()
+}
+
+func (p *ImportStatusCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if p.limit < 1 {
+		log.WithField("Limit", p.limit).Error("limit has to be positive")
+		return subcommands.ExitUsageError
+	}
+	postgresClient, err := postgres.NewPostgresClient(ctx, p.postgresConn)
+	if err != nil {
+		log.WithError(err).Error("can't create postgres client")
+		return subcommands.ExitFailure
+	}
+	defer postgresClient.Close(ctx)
+
+	runs, err := postgres.NewPostgresImportRunRepository(postgresClient).GetImportRuns(ctx, p.limit)
+	if err != nil {
+		log.WithError(err).Error("can't get import runs")
+		return subcommands.ExitFailure
+	}
+	if err := printImportRuns(os.Stdout, runs); err != nil {
+		log.WithError(err).Error("can't print import runs")
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
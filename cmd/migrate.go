@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+
+	"github.com/google/subcommands"
+	"github.com/micro-eshop/catalog/internal/env"
+	"github.com/micro-eshop/catalog/internal/postgres"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+type MigrateCmd struct {
+	postgresConn string
+	migrations   string
+	v            *viper.Viper
+}
+
+func NewMigrateCmd(v *viper.Viper) *MigrateCmd {
+	return &MigrateCmd{v: v}
+}
+
+func (*MigrateCmd) Name() string     { return "migrate" }
+func (*MigrateCmd) Synopsis() string { return "Migrate database schema" }
+func (*MigrateCmd) Usage() string {
+	return `migrate [flags] up | down N | goto V | force V | status
+`
+}
+
+func (p *MigrateCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&p.postgresConn, "postgresConn", p.v.GetString("POSTGRES_CONNECTION"), "postgresConn connection string")
+	f.StringVar(&p.migrations, "migrations", env.GetEnvOrDefault("MIGRATIONS_PATH", ""), "migrations directory, the embedded migrations are used when empty")
+}
+
+type schemaMigrator interface {
+	Up() error
+	Down(steps int) error
+	Goto(version uint) error
+	Force(version int) error
+	Status() (*postgres.MigrationStatus, error)
+}
+
+func parseMigrateArg(args []string) (int, error) {
+	if len(args) != 2 {
+		return 0, fmt.Errorf("%s requires exactly one argument", args[0])
+	}
+	return strconv.Atoi(args[1])
+}
+
+func runMigration(migrator schemaMigrator, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing mode, expected up, down, goto, force or status")
+	}
+	switch args[0] {
+	case "up":
+		return migrator.Up()
+	case "down":
+		steps, err := parseMigrateArg(args)
+		if err != nil {
+			return err
+		}
+		if steps <= 0 {
+			return fmt.Errorf("down requires a positive number of steps")
+		}
+		return migrator.Down(steps)
+	case "goto":
+		version, err := parseMigrateArg(args)
+		if err != nil {
+			return err
+		}
+		if version < 0 {
+			return fmt.Errorf("goto requires a non negative version")
+		}
+		return migrator.Goto(uint(version))
+	case "force":
+		version, err := parseMigrateArg(args)
+		if err != nil {
+			return err
+		}
+		return migrator.Force(version)
+	case "status":
+		status, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("version: %d\ndirty: %t\nlatest: %d\npending: %v\n", status.Version, status.Dirty, status.Latest, status.Pending)
+		return nil
+	default:
+		return fmt.Errorf("unknown mode %q, expected up, down, goto, force or status", args[0])
+	}
+}
+
+func (p *MigrateCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	postgresClient, err := postgres.NewPostgresClient(ctx, p.postgresConn)
+	if err != nil {
+		log.WithError(err).Error("can't create postgres client")
+		return subcommands.ExitFailure
+	}
+	defer postgresClient.Close(ctx)
+	migrator, err := postgres.NewMigrator(ctx, postgresClient, p.migrations)
+	if err != nil {
+		log.WithError(err).Error("can't create migrator")
+		return subcommands.ExitFailure
+	}
+	defer migrator.Close()
+	if err := runMigration(migrator, f.Args()); err != nil {
+		log.WithError(err).WithField("Args", f.Args()).Error("can't migrate database")
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
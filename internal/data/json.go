@@ -0,0 +1,131 @@
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/micro-eshop/catalog/pkg/core/dto"
+	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/services"
+)
+
+// maxNdjsonLineSize bounds a single product line, bufio.Scanner would stop at 64KB by default.
+const maxNdjsonLineSize = 1024 * 1024
+
+// jsonRecord is a product shaped like dto.ProductDto, with the op of a delta feed. Price shadows the price of the dto,
+// so a product without a price is told apart from a free one.
+type jsonRecord struct {
+	dto.ProductDto
+	Price *float64 `json:"price"`
+	Op    string   `json:"op"`
+}
+
+// decodeRecord maps a decoded product to a source record. A value of the wrong type does not stop the decoder,
+// so it is reported as a row error and the source goes on with the next product. A missing price is rejected
+// like an empty price column of a csv file, only the delete rows of a delta don't need one.
+func decodeRecord(line int, product *jsonRecord, err error) *services.SourceRecord {
+	record := &services.SourceRecord{Line: line, Product: product.ToProduct(), Op: services.DeltaOp(strings.ToLower(strings.TrimSpace(product.Op)))}
+	if product.Price != nil {
+		record.Product.Price = *product.Price
+	}
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case err == nil:
+		if product.Price == nil && record.Op != services.DeltaDelete {
+			record.Errors = append(record.Errors, services.RowError{Line: line, ProductID: model.ProductId(product.ID), Column: "price", Reason: "missing"})
+		}
+	case errors.As(err, &typeErr):
+		record.Errors = append(record.Errors, services.RowError{Line: line, ProductID: model.ProductId(product.ID), Column: typeErr.Field, Value: typeErr.Value, Reason: fmt.Sprintf("expected %s", typeErr.Type)})
+	default:
+		record.Errors = append(record.Errors, services.RowError{Line: line, ProductID: model.ProductId(product.ID), Reason: err.Error()})
+	}
+	return record
+}
+
+type jsonProductsSourceDataProvider struct {
+	path string
+}
+
+// NewJsonProductsSourceDataProvider reads a JSON array of products shaped like dto.ProductDto. The array is decoded
+// one product at a time, so the file is never loaded into memory. The line of a record is its position in the array.
+func NewJsonProductsSourceDataProvider(path string) *jsonProductsSourceDataProvider {
+	return &jsonProductsSourceDataProvider{path: path}
+}
+
+func (s *jsonProductsSourceDataProvider) read(ctx context.Context, stream *sourceStream) error {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("can't open json file: %w", err)
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	token, err := decoder.Token()
+	if err != nil {
+		return fmt.Errorf("can't read json file: %w", err)
+	}
+	if token != json.Delim('[') {
+		return errors.New("json file has to contain an array of products")
+	}
+	for position := 1; decoder.More(); position++ {
+		var product jsonRecord
+		err := decoder.Decode(&product)
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("can't read json file: %w", err)
+		}
+		if err := stream.send(ctx, decodeRecord(position, &product, err)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *jsonProductsSourceDataProvider) Provide(ctx context.Context) (<-chan *services.SourceRecord, <-chan error) {
+	return provide(ctx, s.read)
+}
+
+type ndjsonProductsSourceDataProvider struct {
+	path string
+}
+
+// NewNdjsonProductsSourceDataProvider reads newline delimited JSON, one product shaped like dto.ProductDto per line.
+// Blank lines are skipped, a line that is not valid JSON is reported as a row error.
+func NewNdjsonProductsSourceDataProvider(path string) *ndjsonProductsSourceDataProvider {
+	return &ndjsonProductsSourceDataProvider{path: path}
+}
+
+func (s *ndjsonProductsSourceDataProvider) read(ctx context.Context, stream *sourceStream) error {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("can't open ndjson file: %w", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxNdjsonLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		content := bytes.TrimSpace(scanner.Bytes())
+		if len(content) == 0 {
+			continue
+		}
+		var product jsonRecord
+		err := json.Unmarshal(content, &product)
+		if err := stream.send(ctx, decodeRecord(line, &product, err)); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("can't read ndjson file: %w", err)
+	}
+	return nil
+}
+
+func (s *ndjsonProductsSourceDataProvider) Provide(ctx context.Context) (<-chan *services.SourceRecord, <-chan error) {
+	return provide(ctx, s.read)
+}
@@ -0,0 +1,85 @@
+package data
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func readRecords(t *testing.T, provider services.ProductsSourceDataProvider) []*services.SourceRecord {
+	t.Helper()
+	records := make([]*services.SourceRecord, 0)
+	stream, errs := provider.Provide(context.Background())
+	for record := range stream {
+		records = append(records, record)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestJsonProvider(t *testing.T) {
+	records := readRecords(t, NewJsonProductsSourceDataProvider("../../seed/products.json"))
+	csvRecords := readRecords(t, NewProductsSourceDataProvider("../../seed/products.csv", CsvOptions{Columns: DefaultColumnMapping()}))
+	assert.Len(t, records, len(csvRecords))
+	for i, record := range records {
+		assert.Empty(t, record.Errors)
+		assert.Equal(t, i+1, record.Line)
+		assert.Equal(t, csvRecords[i].Product, record.Product)
+	}
+}
+
+func TestJsonProviderWhenValueHasWrongType(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "products.json")
+	content := `[{"id": 1, "name": "Mug", "price": "cheap"}, {"id": 2, "name": "Pin", "price": 1.5}]`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	records := readRecords(t, NewJsonProductsSourceDataProvider(path))
+	assert.Len(t, records, 2)
+	assert.Equal(t, []services.RowError{{Line: 1, ProductID: 1, Column: "price", Value: "string", Reason: "expected float64"}}, records[0].Errors)
+	assert.Empty(t, records[1].Errors)
+	assert.Equal(t, model.NewProduct(model.ProductId(2), "Pin", "", "", 1.5), records[1].Product)
+}
+
+func TestJsonProviderWhenPriceIsMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "products.ndjson")
+	content := "{\"id\": 1, \"name\": \"Mug\"}\n{\"id\": 2, \"name\": \"Pin\", \"price\": 0}\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	records := readRecords(t, NewNdjsonProductsSourceDataProvider(path))
+	assert.Len(t, records, 2)
+	assert.Equal(t, []services.RowError{{Line: 1, ProductID: 1, Column: "price", Reason: "missing"}}, records[0].Errors)
+	assert.Empty(t, records[1].Errors)
+}
+
+func TestNdjsonProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "products.ndjson")
+	content := "{\"id\": 1, \"name\": \"Mug\", \"price\": 2, \"promotionPrice\": 1.5, \"category\": \"Mug\"}\n\n{\"id\": 2, \"name\": \n{\"id\": 3, \"name\": \"Pin\", \"price\": 1}\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	records := readRecords(t, NewNdjsonProductsSourceDataProvider(path))
+	assert.Len(t, records, 3)
+	promotionPrice := 1.5
+	assert.Equal(t, &model.Product{ID: 1, Name: "Mug", Price: 2, PromotionPrice: &promotionPrice, Category: "Mug"}, records[0].Product)
+	assert.Equal(t, 3, records[1].Line)
+	assert.Len(t, records[1].Errors, 1)
+	assert.Equal(t, 4, records[2].Line)
+	assert.Empty(t, records[2].Errors)
+}
+
+func TestDetectFormat(t *testing.T) {
+	assert.Equal(t, CsvFormat, DetectFormat("products.csv"))
+	assert.Equal(t, JsonFormat, DetectFormat("products.JSON"))
+	assert.Equal(t, NdjsonFormat, DetectFormat("products.jsonl"))
+	_, err := NewSourceDataProvider("products.csv", "xml", CsvOptions{})
+	assert.EqualError(t, err, `unknown source format "xml", expected csv, json or ndjson`)
+}
@@ -0,0 +1,150 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ColumnMapping names the CSV header column of every product field. An empty column leaves the field unmapped,
+// id, name and price have to be mapped. Op is only read, and then required, by a delta import.
+type ColumnMapping struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	Brand          string `json:"brand"`
+	Description    string `json:"description"`
+	Price          string `json:"price"`
+	PromotionPrice string `json:"promotionPrice"`
+	Category       string `json:"category"`
+	Op             string `json:"op"`
+}
+
+// DefaultColumnMapping matches the header of seed/products.csv.
+func DefaultColumnMapping() ColumnMapping {
+	return ColumnMapping{
+		ID:             "ProductId",
+		Name:           "Name",
+		Brand:          "Brand",
+		Description:    "Description",
+		Price:          "Price",
+		PromotionPrice: "PromotionPrice",
+		Category:       "CatalogTypeName",
+		Op:             "Op",
+	}
+}
+
+// LoadColumnMapping reads a JSON mapping file, e.g. {"name": "Title", "promotionPrice": ""}. Fields missing from the file keep the default column.
+func LoadColumnMapping(path string) (ColumnMapping, error) {
+	mapping := DefaultColumnMapping()
+	file, err := os.Open(path)
+	if err != nil {
+		return mapping, err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&mapping); err != nil {
+		return mapping, fmt.Errorf("invalid column mapping %s: %w", path, err)
+	}
+	return mapping, nil
+}
+
+func (m *ColumnMapping) field(name string) (*string, error) {
+	switch name {
+	case "id":
+		return &m.ID, nil
+	case "name":
+		return &m.Name, nil
+	case "brand":
+		return &m.Brand, nil
+	case "description":
+		return &m.Description, nil
+	case "price":
+		return &m.Price, nil
+	case "promotionPrice":
+		return &m.PromotionPrice, nil
+	case "category":
+		return &m.Category, nil
+	case "op":
+		return &m.Op, nil
+	default:
+		return nil, fmt.Errorf("unknown product field %q", name)
+	}
+}
+
+// Override applies a comma separated list of field=Column pairs, e.g. "name=Title,promotionPrice=".
+func (m ColumnMapping) Override(overrides string) (ColumnMapping, error) {
+	if strings.TrimSpace(overrides) == "" {
+		return m, nil
+	}
+	for _, pair := range strings.Split(overrides, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		if !ok {
+			return m, fmt.Errorf("invalid column override %q, expected field=Column", pair)
+		}
+		value, err := m.field(strings.TrimSpace(field))
+		if err != nil {
+			return m, err
+		}
+		*value = strings.TrimSpace(column)
+	}
+	return m, nil
+}
+
+// columnIndexes holds the position of every mapped column in a record, -1 for unmapped fields.
+type columnIndexes struct {
+	id, name, brand, description, price, promotionPrice, category, op int
+}
+
+func normalizeColumn(column string) string {
+	return strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+}
+
+// resolve finds the mapped columns in the header row. It fails when a required field is unmapped or a mapped column is missing.
+// The op column is only looked up for a delta.
+func (m ColumnMapping) resolve(header []string, delta bool) (columnIndexes, error) {
+	positions := make(map[string]int, len(header))
+	for i, column := range header {
+		positions[normalizeColumn(column)] = i
+	}
+	missing := make([]string, 0)
+	index := func(field, column string, required bool) int {
+		if column == "" {
+			if required {
+				missing = append(missing, field)
+			}
+			return -1
+		}
+		i, ok := positions[normalizeColumn(column)]
+		if !ok {
+			missing = append(missing, fmt.Sprintf("%s (%s)", field, column))
+			return -1
+		}
+		return i
+	}
+	indexes := columnIndexes{
+		id:             index("id", m.ID, true),
+		name:           index("name", m.Name, true),
+		brand:          index("brand", m.Brand, false),
+		description:    index("description", m.Description, false),
+		price:          index("price", m.Price, true),
+		promotionPrice: index("promotionPrice", m.PromotionPrice, false),
+		category:       index("category", m.Category, false),
+		op:             -1,
+	}
+	if delta {
+		indexes.op = index("op", m.Op, true)
+	}
+	if len(missing) > 0 {
+		return indexes, fmt.Errorf("missing csv columns: %s", strings.Join(missing, ", "))
+	}
+	return indexes, nil
+}
+
+func fieldValue(record []string, index int) string {
+	if index < 0 || index >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[index])
+}
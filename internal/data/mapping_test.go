@@ -0,0 +1,42 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveColumns(t *testing.T) {
+	header := []string{"\ufeffproductid", "Name", "Price", "Brand", "Title"}
+	mapping, err := DefaultColumnMapping().Override("description=Title, promotionPrice=,category=")
+	assert.Nil(t, err)
+	subject, err := mapping.resolve(header, false)
+	assert.Nil(t, err)
+	assert.Equal(t, columnIndexes{id: 0, name: 1, price: 2, brand: 3, description: 4, promotionPrice: -1, category: -1, op: -1}, subject)
+}
+
+func TestResolveColumnsWhenColumnIsMissing(t *testing.T) {
+	_, err := DefaultColumnMapping().resolve([]string{"ProductId", "Name", "Brand"}, false)
+	assert.EqualError(t, err, "missing csv columns: description (Description), price (Price), promotionPrice (PromotionPrice), category (CatalogTypeName)")
+}
+
+func TestResolveColumnsWhenRequiredFieldIsUnmapped(t *testing.T) {
+	mapping, err := DefaultColumnMapping().Override("price=")
+	assert.Nil(t, err)
+	_, err = mapping.resolve([]string{"ProductId", "Name", "Brand", "Description", "Price", "PromotionPrice", "CatalogTypeName"}, false)
+	assert.EqualError(t, err, "missing csv columns: price")
+}
+
+func TestResolveColumnsForDelta(t *testing.T) {
+	header := []string{"ProductId", "Name", "Brand", "Description", "Price", "PromotionPrice", "CatalogTypeName"}
+	_, err := DefaultColumnMapping().resolve(header, true)
+	assert.EqualError(t, err, "missing csv columns: op (Op)")
+	subject, err := DefaultColumnMapping().resolve(append(header, "op"), true)
+	assert.Nil(t, err)
+	assert.Equal(t, 7, subject.op)
+}
+
+func TestOverrideWhenFieldIsUnknown(t *testing.T) {
+	_, err := DefaultColumnMapping().Override("color=Color")
+	assert.EqualError(t, err, `unknown product field "color"`)
+}
@@ -0,0 +1,52 @@
+package data
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/micro-eshop/catalog/pkg/core/services"
+)
+
+var reportHeader = []string{"line", "productId", "column", "value", "reason"}
+
+// WriteErrorReport writes the rejected rows of an import to path, as JSON when the file has a .json extension and as CSV otherwise.
+func WriteErrorReport(path string, rowErrors []services.RowError) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if rowErrors == nil {
+		rowErrors = make([]services.RowError, 0)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(rowErrors); err != nil {
+			return err
+		}
+		return file.Close()
+	}
+	writer := csv.NewWriter(file)
+	if err := writer.Write(reportHeader); err != nil {
+		return err
+	}
+	for _, rowError := range rowErrors {
+		productID := ""
+		if rowError.ProductID != 0 {
+			productID = strconv.Itoa(int(rowError.ProductID))
+		}
+		if err := writer.Write([]string{strconv.Itoa(rowError.Line), productID, rowError.Column, rowError.Value, rowError.Reason}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
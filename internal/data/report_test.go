@@ -0,0 +1,31 @@
+package data
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/micro-eshop/catalog/pkg/core/services"
+	"github.com/stretchr/testify/assert"
+)
+
+var reportErrors = []services.RowError{
+	{Line: 2, ProductID: 1, Column: "Price", Value: "abc", Reason: "not a number"},
+	{Line: 3, Reason: "Name can't be empty"},
+}
+
+func TestWriteCsvErrorReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.csv")
+	assert.Nil(t, WriteErrorReport(path, reportErrors))
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "line,productId,column,value,reason\n2,1,Price,abc,not a number\n3,,,,Name can't be empty\n", string(content))
+}
+
+func TestWriteJsonErrorReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	assert.Nil(t, WriteErrorReport(path, reportErrors))
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `[{"line":2,"productId":1,"column":"Price","value":"abc","reason":"not a number"},{"line":3,"reason":"Name can't be empty"}]`, string(content))
+}
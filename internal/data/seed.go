@@ -3,61 +3,147 @@ package data
 import (
 	"context"
 	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"math/rand"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/services"
 	log "github.com/sirupsen/logrus"
 )
 
+type CsvOptions struct {
+	// Columns maps header columns to product fields. An empty promotion price means the product is not in promotion.
+	Columns ColumnMapping
+	// DemoPromotions gives every other product without a promotion price a random one. Meant for demo data only.
+	DemoPromotions bool
+	// Delta reads the op column of a delta feed. Delete rows only need an id.
+	Delta bool
+}
+
 type productsSourceDataProvider struct {
-	path string
+	path    string
+	options CsvOptions
 }
 
-func NewProductsSourceDataProvider(path string) *productsSourceDataProvider {
-	return &productsSourceDataProvider{path}
+func NewProductsSourceDataProvider(path string, options CsvOptions) *productsSourceDataProvider {
+	return &productsSourceDataProvider{path: path, options: options}
 }
 
-func randomPrice(min, max float64) float64 {
-	return min + rand.Float64()*(max-min)
+// randomPromotionPrice returns a price between 50% and 90% of the regular price, rounded to cents, so it passes validation.
+func randomPromotionPrice(price float64) float64 {
+	return math.Round(price*(0.5+rand.Float64()*0.4)*100) / 100
 }
 
-func parseInt(idsStr string) model.ProductId {
-	res, _ := strconv.Atoi(idsStr)
-	return model.ProductId(res)
+// recordParser turns CSV records into products and collects a RowError for every value that can't be parsed.
+type recordParser struct {
+	record services.SourceRecord
+	values []string
 }
 
-func parseFloat(idsStr string) float64 {
-	res, _ := strconv.ParseFloat(idsStr, 64)
+func (p *recordParser) fail(column, value, reason string) {
+	p.record.Errors = append(p.record.Errors, services.RowError{Line: p.record.Line, Column: column, Value: value, Reason: reason})
+}
+
+func (p *recordParser) parseInt(column string, index int) int {
+	value := fieldValue(p.values, index)
+	res, err := strconv.Atoi(value)
+	if err != nil {
+		p.fail(column, value, "not an integer")
+	}
 	return res
 }
 
-func (s *productsSourceDataProvider) Provide(ctx context.Context) <-chan *model.Product {
-	stream := make(chan *model.Product)
+func (p *recordParser) parseFloat(column string, index int) float64 {
+	value := fieldValue(p.values, index)
+	res, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		p.fail(column, value, "not a number")
+	}
+	return res
+}
 
-	go func() {
-		csvFile, err := os.Open(s.path)
-		if err != nil {
-			log.WithError(err).Fatalln("error while opening csv file")
+// parseOptionalFloat returns nil for an empty value.
+func (p *recordParser) parseOptionalFloat(column string, index int) *float64 {
+	if fieldValue(p.values, index) == "" {
+		return nil
+	}
+	res := p.parseFloat(column, index)
+	return &res
+}
+
+func (s *productsSourceDataProvider) parseRecord(line int, values []string, columns columnIndexes) *services.SourceRecord {
+	mapping := s.options.Columns
+	parser := &recordParser{record: services.SourceRecord{Line: line}, values: values}
+	parser.record.Op = services.DeltaOp(strings.ToLower(fieldValue(values, columns.op)))
+	id := model.ProductId(parser.parseInt(mapping.ID, columns.id))
+	if parser.record.Op == services.DeltaDelete {
+		parser.record.Product = &model.Product{ID: id}
+		return &parser.record
+	}
+	price := parser.parseFloat(mapping.Price, columns.price)
+	product := model.NewProduct(id, fieldValue(values, columns.name), fieldValue(values, columns.brand), fieldValue(values, columns.description), price)
+	product.PromotionPrice = parser.parseOptionalFloat(mapping.PromotionPrice, columns.promotionPrice)
+	product.Category = fieldValue(values, columns.category)
+	for i := range parser.record.Errors {
+		parser.record.Errors[i].ProductID = id
+	}
+	parser.record.Product = product
+	return &parser.record
+}
+
+func (s *productsSourceDataProvider) read(ctx context.Context, stream *sourceStream) error {
+	csvFile, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("can't open csv file: %w", err)
+	}
+	log.Infoln("Successfully Opened CSV file")
+	defer csvFile.Close()
+	reader := csv.NewReader(csvFile)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("can't read csv header: %w", err)
+	}
+	columns, err := s.options.Columns.resolve(header, s.options.Delta)
+	if err != nil {
+		return fmt.Errorf("csv header does not match the column mapping: %w", err)
+	}
+	for i := 0; ; i++ {
+		values, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			record := &services.SourceRecord{Line: parseErr.StartLine, Errors: []services.RowError{{Line: parseErr.StartLine, Reason: parseErr.Err.Error()}}}
+			if err := stream.send(ctx, record); err != nil {
+				return err
+			}
+			continue
 		}
-		log.Infoln("Successfully Opened CSV file")
-		defer csvFile.Close()
-		csvLines, err := csv.NewReader(csvFile).ReadAll()
 		if err != nil {
-			log.WithError(err).Fatalln("error while reading csv file")
+			return fmt.Errorf("can't read csv file: %w", err)
 		}
-		for i, p := range csvLines {
-			id := parseInt(p[0])
-			price := parseFloat(p[5])
-
-			if i%2 == 0 {
-				stream <- model.NewProduct(id, p[2], p[3], p[4], price)
-			} else {
-				stream <- model.NewPromotionalProduct(id, p[2], p[3], p[4], price, randomPrice(1, 10))
-			}
+		line, _ := reader.FieldPos(0)
+		record := s.parseRecord(line, values, columns)
+		if record.Product.PromotionPrice == nil && s.options.DemoPromotions && i%2 == 0 && record.Product.Price > 0 {
+			promotionPrice := randomPromotionPrice(record.Product.Price)
+			record.Product.PromotionPrice = &promotionPrice
+		}
+		if err := stream.send(ctx, record); err != nil {
+			return err
 		}
-		close(stream)
-	}()
-	return stream
+	}
+}
+
+// Provide reads the csv file record by record. It stops when ctx is cancelled, the error channel gets the reason
+// the file could not be read to the end.
+func (s *productsSourceDataProvider) Provide(ctx context.Context) (<-chan *services.SourceRecord, <-chan error) {
+	return provide(ctx, s.read)
 }
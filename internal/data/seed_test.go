@@ -0,0 +1,114 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvide(t *testing.T) {
+	provider := NewProductsSourceDataProvider("../../seed/products.csv", CsvOptions{Columns: DefaultColumnMapping()})
+	records := make([]*services.SourceRecord, 0)
+	for _, record := range readRecords(t, provider) {
+		assert.Empty(t, record.Errors)
+		records = append(records, record)
+	}
+	assert.Len(t, records, 14)
+	promotionPrice := 15.0
+	expected := &model.Product{ID: 1, Name: ".NET Bot Black Hoodie", Brand: ".NET", Description: ".NET Bot Black Hoodie, and more", Price: 19.5, PromotionPrice: &promotionPrice, Category: "T-Shirt"}
+	assert.Equal(t, 2, records[0].Line)
+	assert.Equal(t, expected, records[0].Product)
+}
+
+func TestProvidePromotionPrices(t *testing.T) {
+	provider := NewProductsSourceDataProvider("../../seed/products.csv", CsvOptions{Columns: DefaultColumnMapping()})
+	promotions := make(map[model.ProductId]float64)
+	for _, record := range readRecords(t, provider) {
+		if record.Product.PromotionPrice != nil {
+			promotions[record.Product.ID] = *record.Product.PromotionPrice
+		}
+	}
+	assert.Equal(t, map[model.ProductId]float64{1: 15, 4: 9.99, 9: 10, 13: 6.5}, promotions)
+}
+
+func TestRandomPromotionPrice(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		price := randomPromotionPrice(12)
+		assert.GreaterOrEqual(t, price, 6.0)
+		assert.Less(t, price, 12.0)
+	}
+}
+
+func TestProvideRowErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "products.csv")
+	content := "ProductId,Name,Price\n1,Mug,abc\nx,Pin,1\n3,\"Cup,2\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mapping, _ := DefaultColumnMapping().Override("brand=,description=,promotionPrice=,category=")
+	provider := NewProductsSourceDataProvider(path, CsvOptions{Columns: mapping})
+	rowErrors := make([]services.RowError, 0)
+	for _, record := range readRecords(t, provider) {
+		rowErrors = append(rowErrors, record.Errors...)
+	}
+	assert.Len(t, rowErrors, 3)
+	assert.Equal(t, services.RowError{Line: 2, ProductID: 1, Column: "Price", Value: "abc", Reason: "not a number"}, rowErrors[0])
+	assert.Equal(t, services.RowError{Line: 3, Column: "ProductId", Value: "x", Reason: "not an integer"}, rowErrors[1])
+	assert.Equal(t, 4, rowErrors[2].Line)
+}
+
+func TestProvideDelta(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "delta.csv")
+	content := "Op,ProductId,Name,Price\nUpsert,1,Mug,2.5\ndelete,2,,\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mapping, _ := DefaultColumnMapping().Override("brand=,description=,promotionPrice=,category=")
+	records := readRecords(t, NewProductsSourceDataProvider(path, CsvOptions{Columns: mapping, Delta: true}))
+	assert.Len(t, records, 2)
+	assert.Equal(t, services.DeltaUpsert, records[0].Op)
+	assert.Equal(t, 2.5, records[0].Product.Price)
+	assert.Equal(t, services.DeltaDelete, records[1].Op)
+	assert.Equal(t, &model.Product{ID: 2}, records[1].Product)
+	assert.Empty(t, records[1].Errors)
+}
+
+func TestProvideWhenFileIsMissing(t *testing.T) {
+	provider := NewProductsSourceDataProvider(filepath.Join(t.TempDir(), "missing.csv"), CsvOptions{Columns: DefaultColumnMapping()})
+	stream, errs := provider.Provide(context.Background())
+	for range stream {
+		t.Fatal("no record expected")
+	}
+	err := <-errs
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestProvideWhenHeaderDoesNotMatchMapping(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "products.csv")
+	if err := os.WriteFile(path, []byte("Id,Title\n1,Mug\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	provider := NewProductsSourceDataProvider(path, CsvOptions{Columns: DefaultColumnMapping()})
+	stream, errs := provider.Provide(context.Background())
+	for range stream {
+		t.Fatal("no record expected")
+	}
+	assert.ErrorContains(t, <-errs, "missing csv columns")
+}
+
+func TestProvideStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	provider := NewProductsSourceDataProvider("../../seed/products.csv", CsvOptions{Columns: DefaultColumnMapping()})
+	cancel()
+	stream, errs := provider.Provide(ctx)
+	// Nothing receives the records, so the provider can only stop because of the cancellation.
+	assert.True(t, errors.Is(<-errs, context.Canceled))
+	_, open := <-stream
+	assert.False(t, open)
+}
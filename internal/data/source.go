@@ -0,0 +1,93 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/micro-eshop/catalog/pkg/core/services"
+)
+
+const (
+	CsvFormat    = "csv"
+	JsonFormat   = "json"
+	NdjsonFormat = "ndjson"
+)
+
+// sourceStream carries the records of a provider and the error that stopped it, if any.
+type sourceStream struct {
+	records chan *services.SourceRecord
+	errs    chan error
+}
+
+// provide runs read in its own goroutine and closes the stream with the error it returns.
+func provide(ctx context.Context, read func(ctx context.Context, stream *sourceStream) error) (<-chan *services.SourceRecord, <-chan error) {
+	stream := &sourceStream{records: make(chan *services.SourceRecord), errs: make(chan error, 1)}
+	go func() {
+		if err := read(ctx, stream); err != nil {
+			stream.errs <- err
+		}
+		close(stream.records)
+		close(stream.errs)
+	}()
+	return stream.records, stream.errs
+}
+
+// send blocks until the record is consumed, it returns the context error when the import is cancelled first.
+func (s *sourceStream) send(ctx context.Context, record *services.SourceRecord) error {
+	select {
+	case s.records <- record:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// DetectFormat picks the source format from the file extension, files with an unknown extension are read as CSV.
+func DetectFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JsonFormat
+	case ".ndjson", ".jsonl":
+		return NdjsonFormat
+	default:
+		return CsvFormat
+	}
+}
+
+// NewSourceDataProvider returns the provider for format, or for the extension of path when format is empty.
+// The CSV options only apply to CSV files, JSON products always use the fields of dto.ProductDto.
+func NewSourceDataProvider(path, format string, options CsvOptions) (services.ProductsSourceDataProvider, error) {
+	if format == "" {
+		format = DetectFormat(path)
+	}
+	switch format {
+	case CsvFormat:
+		return NewProductsSourceDataProvider(path, options), nil
+	case JsonFormat:
+		return NewJsonProductsSourceDataProvider(path), nil
+	case NdjsonFormat:
+		return NewNdjsonProductsSourceDataProvider(path), nil
+	default:
+		return nil, fmt.Errorf("unknown source format %q, expected %s, %s or %s", format, CsvFormat, JsonFormat, NdjsonFormat)
+	}
+}
+
+// FileChecksum returns the hex encoded SHA-256 of the file, it tells whether a source changed between import runs.
+func FileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
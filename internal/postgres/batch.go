@@ -0,0 +1,173 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+)
+
+// products_import only lives until the batch transaction ends, so concurrent batches don't see each other's rows.
+const createImportTable = `CREATE TEMP TABLE products_import (
+  id INTEGER NOT NULL,
+  name TEXT NOT NULL,
+  description TEXT,
+  price DOUBLE PRECISION NOT NULL,
+  brand TEXT,
+  brand_slug TEXT NOT NULL,
+  promotion_price DOUBLE PRECISION NULL,
+  category TEXT,
+  content_hash TEXT NOT NULL
+) ON COMMIT DROP`
+
+// dropImportTable lets UpsertBatch run again in the same transaction, as a delta import does for every row.
+const dropImportTable = `DROP TABLE products_import`
+
+var importColumns = []string{"id", "name", "description", "price", "brand", "brand_slug", "promotion_price", "category", "content_hash"}
+
+// The first spelling of a new brand in the batch becomes its display name, like resolveBrand does for single products.
+const mergeImportedBrands = `INSERT INTO brands (slug, name)
+SELECT DISTINCT ON (brand_slug) brand_slug, trim(brand) FROM products_import
+WHERE brand_slug <> ''
+ORDER BY brand_slug, id
+ON CONFLICT (slug) DO NOTHING`
+
+// xmax is 0 only for rows inserted by this statement, so it tells created products from updated ones. The archived
+// CTE sees the rows as they were before the statement, so it tells restored products apart.
+// Products with the same content hash are not touched and not returned, unless they have to be restored from the archive.
+// Rows are written in id order, so concurrent writers lock them in the same order.
+const mergeImportedProducts = `WITH archived AS (
+  SELECT p.id FROM products p JOIN products_import i ON i.id = p.id WHERE p.archived_at IS NOT NULL
+)
+INSERT INTO products (id, name, description, price, brand, brand_id, promotion_price, content_hash)
+SELECT i.id, i.name, i.description, i.price, coalesce(b.name, i.brand), b.id, i.promotion_price, i.content_hash
+FROM products_import i LEFT JOIN brands b ON b.slug = i.brand_slug
+ORDER BY i.id
+ON CONFLICT (id) DO UPDATE SET
+  name = EXCLUDED.name,
+  description = EXCLUDED.description,
+  price = EXCLUDED.price,
+  brand = EXCLUDED.brand,
+  brand_id = EXCLUDED.brand_id,
+  promotion_price = EXCLUDED.promotion_price,
+  content_hash = EXCLUDED.content_hash,
+  archived_at = NULL
+WHERE products.content_hash IS DISTINCT FROM EXCLUDED.content_hash OR products.archived_at IS NOT NULL
+RETURNING id, brand, (xmax = 0) AS created, id IN (SELECT id FROM archived) AS restored`
+
+const mergeImportedCategories = `INSERT INTO categories (name)
+SELECT DISTINCT ON (lower(category)) category FROM products_import
+WHERE category <> ''
+ORDER BY lower(category), id
+ON CONFLICT ((lower(name))) DO NOTHING`
+
+const unlinkImportedCategories = `DELETE FROM product_categories pc USING products_import i
+WHERE pc.product_id = i.id
+  AND NOT EXISTS (SELECT 1 FROM categories c WHERE c.id = pc.category_id AND lower(c.name) = lower(i.category))`
+
+const linkImportedCategories = `INSERT INTO product_categories (product_id, category_id)
+SELECT i.id, c.id FROM products_import i JOIN categories c ON lower(c.name) = lower(i.category)
+WHERE i.category <> ''
+ON CONFLICT DO NOTHING`
+
+// lastById drops all but the last product of every id, ON CONFLICT can't update the same row twice in one statement.
+func lastById(products []*model.Product) []*model.Product {
+	seen := make(map[model.ProductId]bool, len(products))
+	result := make([]*model.Product, 0, len(products))
+	for i := len(products) - 1; i >= 0; i-- {
+		if seen[products[i].ID] {
+			continue
+		}
+		seen[products[i].ID] = true
+		result = append(result, products[i])
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
+
+func copyProducts(ctx context.Context, tx *sql.Tx, products []*model.Product) error {
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("products_import", importColumns...))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, product := range products {
+		dbProduct := newPostgresProduct(product)
+		_, err := stmt.ExecContext(ctx, dbProduct.ProductID, dbProduct.Name, dbProduct.Description, dbProduct.Price,
+			dbProduct.Brand, model.NewBrandSlug(dbProduct.Brand), dbProduct.PromotionPrice, dbProduct.Category.String, model.ContentHash(product))
+		if err != nil {
+			return err
+		}
+	}
+	_, err = stmt.ExecContext(ctx)
+	return err
+}
+
+// UpsertBatch loads the batch with COPY into a temporary table and merges it into products, brands and categories
+// with a few set based statements in one transaction. Like Insert, it replaces product.Brand with the canonical brand name
+// of created and updated products.
+func (r *postgresCatalogRepository) UpsertBatch(ctx context.Context, products []*model.Product) (repositories.UpsertResult, error) {
+	result := make(repositories.UpsertResult, len(products))
+	if len(products) == 0 {
+		return result, nil
+	}
+	batch := lastById(products)
+	brands := make(map[model.ProductId]string, len(batch))
+	err := r.client.transaction(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, createImportTable); err != nil {
+			return err
+		}
+		if err := copyProducts(ctx, tx, batch); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, mergeImportedBrands); err != nil {
+			return err
+		}
+		rows, err := tx.QueryContext(ctx, mergeImportedProducts)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id int
+			var brand sql.NullString
+			var created, restored bool
+			if err := rows.Scan(&id, &brand, &created, &restored); err != nil {
+				return err
+			}
+			status := repositories.UpsertUpdated
+			switch {
+			case created:
+				status = repositories.UpsertCreated
+			case restored:
+				status = repositories.UpsertRestored
+			}
+			result[model.ProductId(id)] = status
+			brands[model.ProductId(id)] = brand.String
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, statement := range []string{mergeImportedCategories, unlinkImportedCategories, linkImportedCategories, dropImportTable} {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, product := range products {
+		if brand, ok := brands[product.ID]; ok {
+			product.Brand = brand
+		} else {
+			result[product.ID] = repositories.UpsertUnchanged
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dominikus1993/integrationtestcontainers-go"
+	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLastById(t *testing.T) {
+	first := model.NewProduct(model.ProductId(1), "first", "brand", "description", 1.0)
+	second := model.NewProduct(model.ProductId(2), "second", "brand", "description", 1.0)
+	last := model.NewProduct(model.ProductId(1), "last", "brand", "description", 1.0)
+	subject := lastById([]*model.Product{first, second, last})
+	assert.Equal(t, []*model.Product{second, last}, subject)
+}
+
+func TestUpsertBatch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	ctx := context.Background()
+	postgres, err := integrationtestcontainers.StartPostgreSqlContainer(ctx, integrationtestcontainers.DefaultPostgresContainerConfiguration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer postgres.Terminate(ctx)
+	db, err := NewPostgresClient(ctx, postgres.ConnectionString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close(ctx)
+	migrateUp(ctx, t, db)
+	repository := NewPostgresCatalogRepository(db)
+	existing := model.NewProduct(model.ProductId(1), "name", ".NET", "description", 1.0)
+	if err := repository.Insert(ctx, existing); err != nil {
+		t.Fatal(err)
+	}
+
+	updated := model.NewPromotionalProduct(model.ProductId(1), "new name", ".net", "description", 2.0, 1.5)
+	updated.Category = "Mug"
+	created := model.NewProduct(model.ProductId(2), "other", "brand", "description", 1.0)
+	result, err := repository.UpsertBatch(ctx, []*model.Product{updated, created})
+	assert.Nil(t, err)
+	assert.Equal(t, repositories.UpsertResult{1: repositories.UpsertUpdated, 2: repositories.UpsertCreated}, result)
+	assert.Equal(t, ".NET", updated.Brand)
+
+	dbproduct, err := repository.GetProductById(ctx, updated.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, updated, dbproduct)
+	dbproduct, err = repository.GetProductById(ctx, created.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, created, dbproduct)
+
+	t.Run("when importing the same content again", func(t *testing.T) {
+		same := model.NewPromotionalProduct(model.ProductId(1), "new name", ".net", "description", 2.0, 1.5)
+		same.Category = "Mug"
+		changed := model.NewProduct(model.ProductId(2), "other", "brand", "description", 3.0)
+		result, err := repository.UpsertBatch(ctx, []*model.Product{same, changed})
+		assert.Nil(t, err)
+		assert.Equal(t, repositories.UpsertResult{1: repositories.UpsertUnchanged, 2: repositories.UpsertUpdated}, result)
+	})
+
+	t.Run("when restoring an archived product", func(t *testing.T) {
+		assert.Nil(t, repository.Archive(ctx, model.ProductId(2)))
+		dbproduct, err := repository.GetProductById(ctx, model.ProductId(2))
+		assert.Nil(t, err)
+		assert.Nil(t, dbproduct)
+		ids, err := repository.GetProductIds(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, []model.ProductId{1}, ids)
+		stored, err := repository.GetStoredProducts(ctx, model.ProductId(2))
+		assert.Nil(t, err)
+		assert.True(t, stored[model.ProductId(2)].Archived)
+
+		restored := model.NewProduct(model.ProductId(2), "other", "brand", "description", 3.0)
+		assert.Equal(t, model.ContentHash(restored), stored[model.ProductId(2)].ContentHash)
+		result, err := repository.UpsertBatch(ctx, []*model.Product{restored})
+		assert.Nil(t, err)
+		assert.Equal(t, repositories.UpsertResult{2: repositories.UpsertRestored}, result)
+		dbproduct, err = repository.GetProductById(ctx, model.ProductId(2))
+		assert.Nil(t, err)
+		assert.Equal(t, restored, dbproduct)
+	})
+}
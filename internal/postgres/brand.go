@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/micro-eshop/catalog/pkg/core/model"
+)
+
+// resolveBrand returns the brand matching the normalized name, creating it when it does not exist yet.
+// Products without a brand, or with a name that normalizes to an empty slug, are not linked to any brand.
+func resolveBrand(ctx context.Context, tx *sql.Tx, name string) (sql.NullInt64, string, error) {
+	slug := model.NewBrandSlug(name)
+	if slug == "" {
+		return sql.NullInt64{}, name, nil
+	}
+	var id int64
+	var brandName string
+	err := tx.QueryRowContext(ctx, `INSERT INTO brands (slug, name) VALUES ($1, $2)
+		ON CONFLICT (slug) DO UPDATE SET slug = EXCLUDED.slug
+		RETURNING id, name`, slug, strings.TrimSpace(name)).Scan(&id, &brandName)
+	if err != nil {
+		return sql.NullInt64{}, name, err
+	}
+	return sql.NullInt64{Int64: id, Valid: true}, brandName, nil
+}
+
+type postgresBrand struct {
+	ID      int            `pg:"id"`
+	Slug    string         `pg:"slug"`
+	Name    string         `pg:"name"`
+	LogoURL sql.NullString `pg:"logo_url"`
+}
+
+func (b postgresBrand) toBrand() *model.Brand {
+	var logoURL *string
+	if b.LogoURL.Valid {
+		logoURL = &b.LogoURL.String
+	}
+	return &model.Brand{ID: model.BrandId(b.ID), Slug: b.Slug, Name: b.Name, LogoURL: logoURL}
+}
+
+func mapBrand(scanner sq.RowScanner) (*postgresBrand, error) {
+	var dbBrand postgresBrand
+	err := scanner.Scan(&dbBrand.ID, &dbBrand.Slug, &dbBrand.Name, &dbBrand.LogoURL)
+	if err != nil {
+		return nil, err
+	}
+	return &dbBrand, nil
+}
+
+type postgresBrandRepository struct {
+	client *postgresClient
+}
+
+func NewPostgresBrandRepository(postgresClient *postgresClient) *postgresBrandRepository {
+	return &postgresBrandRepository{client: postgresClient}
+}
+
+func (r *postgresBrandRepository) GetBrands(ctx context.Context) ([]*model.Brand, error) {
+	query := psql.Select("id", "slug", "name", "logo_url").From("brands").OrderBy("name", "id")
+	rows, err := query.RunWith(r.client.runner(ctx)).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	brands := make([]*model.Brand, 0)
+	for rows.Next() {
+		brand, err := mapBrand(rows)
+		if err != nil {
+			return nil, err
+		}
+		brands = append(brands, brand.toBrand())
+	}
+	return brands, rows.Err()
+}
+
+func (r *postgresBrandRepository) GetBrandBySlug(ctx context.Context, slug string) (*model.Brand, error) {
+	query := psql.Select("id", "slug", "name", "logo_url").From("brands").Where(sq.Eq{"slug": model.NewBrandSlug(slug)})
+	brand, err := mapBrand(query.RunWith(r.client.runner(ctx)).QueryRowContext(ctx))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return brand.toBrand(), nil
+}
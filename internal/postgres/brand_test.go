@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dominikus1993/integrationtestcontainers-go"
+	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBrands(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	ctx := context.Background()
+	postgres, err := integrationtestcontainers.StartPostgreSqlContainer(ctx, integrationtestcontainers.DefaultPostgresContainerConfiguration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer postgres.Terminate(ctx)
+	db, err := NewPostgresClient(ctx, postgres.ConnectionString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close(ctx)
+	migrateUp(ctx, t, db)
+	repository := NewPostgresCatalogRepository(db)
+	brands := NewPostgresBrandRepository(db)
+
+	first := model.NewProduct(model.ProductId(1), "name", ".NET", "description", 1.0)
+	second := model.NewProduct(model.ProductId(2), "name", " .net", "description", 1.0)
+	for _, product := range []*model.Product{first, second} {
+		if err := repository.Insert(ctx, product); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("when brand spelling differs", func(t *testing.T) {
+		subject, err := brands.GetBrands(ctx)
+		assert.Nil(t, err)
+		assert.Len(t, subject, 1)
+		assert.Equal(t, "net", subject[0].Slug)
+		assert.Equal(t, ".NET", subject[0].Name)
+		assert.Equal(t, ".NET", second.Brand)
+	})
+
+	t.Run("when brand does exist", func(t *testing.T) {
+		subject, err := brands.GetBrandBySlug(ctx, "NET")
+		assert.Nil(t, err)
+		assert.NotNil(t, subject)
+		assert.Equal(t, ".NET", subject.Name)
+	})
+
+	t.Run("when brand does not exist", func(t *testing.T) {
+		subject, err := brands.GetBrandBySlug(ctx, "other")
+		assert.Nil(t, err)
+		assert.Nil(t, subject)
+	})
+}
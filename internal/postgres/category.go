@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/micro-eshop/catalog/pkg/core/model"
+)
+
+// categoryProductsFilter matches products linked to the category or to any of its descendants.
+const categoryProductsFilter = `id IN (
+  WITH RECURSIVE tree AS (
+    SELECT id FROM categories WHERE id = ?
+    UNION
+    SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id
+  )
+  SELECT pc.product_id FROM product_categories pc JOIN tree t ON t.id = pc.category_id
+)`
+
+// setProductCategory links the product to the category with the given name, creating a root category when none exists yet.
+// Names are matched case-insensitively, so an existing category keeps its place in the hierarchy. An empty name unlinks the product.
+func setProductCategory(ctx context.Context, tx *sql.Tx, productID int, name string) error {
+	if name == "" {
+		_, err := tx.ExecContext(ctx, `DELETE FROM product_categories WHERE product_id = $1`, productID)
+		return err
+	}
+	var categoryID int
+	err := tx.QueryRowContext(ctx, `INSERT INTO categories (name) VALUES ($1)
+		ON CONFLICT ((lower(name))) DO UPDATE SET name = categories.name
+		RETURNING id`, name).Scan(&categoryID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM product_categories WHERE product_id = $1 AND category_id <> $2`, productID, categoryID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO product_categories (product_id, category_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, productID, categoryID)
+	return err
+}
+
+type postgresCategory struct {
+	ID       int           `pg:"id"`
+	Name     string        `pg:"name"`
+	ParentID sql.NullInt64 `pg:"parent_id"`
+}
+
+func (c postgresCategory) toCategory() *model.Category {
+	var parentID *model.CategoryId
+	if c.ParentID.Valid {
+		id := model.CategoryId(c.ParentID.Int64)
+		parentID = &id
+	}
+	return model.NewCategory(model.CategoryId(c.ID), c.Name, parentID)
+}
+
+func mapCategory(scanner sq.RowScanner) (*postgresCategory, error) {
+	var dbCategory postgresCategory
+	err := scanner.Scan(&dbCategory.ID, &dbCategory.Name, &dbCategory.ParentID)
+	if err != nil {
+		return nil, err
+	}
+	return &dbCategory, nil
+}
+
+type postgresCategoryRepository struct {
+	client *postgresClient
+}
+
+func NewPostgresCategoryRepository(postgresClient *postgresClient) *postgresCategoryRepository {
+	return &postgresCategoryRepository{client: postgresClient}
+}
+
+func (r *postgresCategoryRepository) GetCategories(ctx context.Context) ([]*model.Category, error) {
+	query := psql.Select("id", "name", "parent_id").From("categories").OrderBy("id")
+	rows, err := query.RunWith(r.client.runner(ctx)).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	categories := make([]*model.Category, 0)
+	for rows.Next() {
+		category, err := mapCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category.toCategory())
+	}
+	return categories, rows.Err()
+}
+
+func (r *postgresCategoryRepository) GetCategoryById(ctx context.Context, id model.CategoryId) (*model.Category, error) {
+	query := psql.Select("id", "name", "parent_id").From("categories").Where(sq.Eq{"id": int(id)})
+	category, err := mapCategory(query.RunWith(r.client.runner(ctx)).QueryRowContext(ctx))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return category.toCategory(), nil
+}
@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dominikus1993/integrationtestcontainers-go"
+	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCategories(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	ctx := context.Background()
+	postgres, err := integrationtestcontainers.StartPostgreSqlContainer(ctx, integrationtestcontainers.DefaultPostgresContainerConfiguration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer postgres.Terminate(ctx)
+	db, err := NewPostgresClient(ctx, postgres.ConnectionString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close(ctx)
+	migrateUp(ctx, t, db)
+	repository := NewPostgresCatalogRepository(db)
+	categories := NewPostgresCategoryRepository(db)
+
+	hoodie := model.NewProduct(model.ProductId(1), "hoodie", "brand", "description", 1.0)
+	hoodie.Category = "Hoodie"
+	mug := model.NewProduct(model.ProductId(2), "mug", "brand", "description", 1.0)
+	mug.Category = "Mug"
+	for _, product := range []*model.Product{hoodie, mug} {
+		if err := repository.Insert(ctx, product); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var apparelID int
+	err = db.db.QueryRowContext(ctx, "INSERT INTO categories (name) VALUES ('Apparel') RETURNING id").Scan(&apparelID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.db.ExecContext(ctx, "UPDATE categories SET parent_id = $1 WHERE name = 'Hoodie'", apparelID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("when reading product category", func(t *testing.T) {
+		product, err := repository.GetProductById(ctx, hoodie.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, "Hoodie", product.Category)
+	})
+
+	t.Run("when listing categories", func(t *testing.T) {
+		subject, err := categories.GetCategories(ctx)
+		assert.Nil(t, err)
+		assert.Len(t, subject, 3)
+	})
+
+	t.Run("when searching products of parent category", func(t *testing.T) {
+		page, err := repository.Search(ctx, repositories.ProductSearchParams{CategoryID: model.CategoryId(apparelID)})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, page.Total)
+		assert.Equal(t, hoodie.ID, page.Products[0].ID)
+	})
+
+	t.Run("when category does not exist", func(t *testing.T) {
+		subject, err := categories.GetCategoryById(ctx, model.CategoryId(1000))
+		assert.Nil(t, err)
+		assert.Nil(t, subject)
+	})
+}
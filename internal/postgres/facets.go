@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+)
+
+func (r *postgresCatalogRepository) brandFacet(ctx context.Context, params repositories.ProductSearchParams) ([]repositories.FacetCount, error) {
+	params.Brand, params.Brands = "", nil
+	query := applySearchFilters(psql.Select("coalesce(brand, '') AS value", "count(*)").From("products"), params).
+		GroupBy("value").
+		OrderBy("count(*) DESC", "value")
+	rows, err := query.RunWith(r.client.runner(ctx)).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make([]repositories.FacetCount, 0)
+	for rows.Next() {
+		var facet repositories.FacetCount
+		if err := rows.Scan(&facet.Value, &facet.Count); err != nil {
+			return nil, err
+		}
+		result = append(result, facet)
+	}
+	return result, rows.Err()
+}
+
+func (r *postgresCatalogRepository) priceFacet(ctx context.Context, params repositories.ProductSearchParams) ([]repositories.PriceBucketCount, error) {
+	params.PriceFrom, params.PriceTo = 0, 0
+	query := applySearchFilters(psql.Select().Column("width_bucket(price, ?::float8[]) AS bucket", pq.Array(repositories.PriceBucketBounds)).Column("count(*)").From("products"), params).
+		GroupBy("bucket")
+	rows, err := query.RunWith(r.client.runner(ctx)).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := repositories.NewPriceBucketCounts(repositories.PriceBucketBounds)
+	for rows.Next() {
+		var bucket, count int
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, err
+		}
+		if bucket >= 0 && bucket < len(result) {
+			result[bucket].Count = count
+		}
+	}
+	return result, rows.Err()
+}
+
+func (r *postgresCatalogRepository) promotionFacet(ctx context.Context, params repositories.ProductSearchParams) (repositories.PromotionFacet, error) {
+	params.InPromotion = false
+	query := applySearchFilters(psql.Select("promotion_price IS NOT NULL AS in_promotion", "count(*)").From("products"), params).
+		GroupBy("in_promotion")
+	var result repositories.PromotionFacet
+	rows, err := query.RunWith(r.client.runner(ctx)).QueryContext(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var inPromotion bool
+		var count int
+		if err := rows.Scan(&inPromotion, &count); err != nil {
+			return result, err
+		}
+		if inPromotion {
+			result.InPromotion = count
+		} else {
+			result.NotInPromotion = count
+		}
+	}
+	return result, rows.Err()
+}
+
+func (r *postgresCatalogRepository) Facets(ctx context.Context, params repositories.ProductSearchParams) (*repositories.ProductFacets, error) {
+	brands, err := r.brandFacet(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	prices, err := r.priceFacet(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	promotion, err := r.promotionFacet(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return &repositories.ProductFacets{Brands: brands, PriceBuckets: prices, Promotion: promotion}, nil
+}
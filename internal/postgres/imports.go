@@ -0,0 +1,229 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+	coreerr "github.com/micro-eshop/catalog/pkg/core/error"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+)
+
+var importRunColumns = []string{"id", "source_path", "checksum", "status", "committed_offset", "records", "created", "restored", "updated", "unchanged", "invalid", "failed", "archived", "error", "row_errors", "options", "owner", "cancel_requested", "started_at", "updated_at", "finished_at"}
+
+// unfinishedImportJob matches the jobs that still have to run, runs without options were started from the command line.
+const unfinishedImportJob = "options IS NOT NULL AND status IN ('queued', 'running')"
+
+func mapImportRun(scanner sq.RowScanner) (*repositories.ImportRun, error) {
+	var run repositories.ImportRun
+	var status string
+	var runError sql.NullString
+	var rowErrors []byte
+	var options []byte
+	var owner sql.NullString
+	var finishedAt sql.NullTime
+	err := scanner.Scan(&run.ID, &run.SourcePath, &run.Checksum, &status, &run.Offset, &run.Records, &run.Created, &run.Restored, &run.Updated, &run.Unchanged, &run.Invalid, &run.Failed, &run.Archived,
+		&runError, &rowErrors, &options, &owner, &run.CancelRequested, &run.StartedAt, &run.UpdatedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+	run.Status = repositories.ImportRunStatus(status)
+	run.Error = runError.String
+	run.Owner = owner.String
+	if err := json.Unmarshal(rowErrors, &run.RowErrors); err != nil {
+		return nil, err
+	}
+	if options != nil {
+		run.Options = &repositories.ImportJobOptions{}
+		if err := json.Unmarshal(options, run.Options); err != nil {
+			return nil, err
+		}
+	}
+	if finishedAt.Valid {
+		run.FinishedAt = &finishedAt.Time
+	}
+	return &run, nil
+}
+
+func mapImportRuns(rows *sql.Rows) ([]*repositories.ImportRun, error) {
+	defer rows.Close()
+	runs := make([]*repositories.ImportRun, 0)
+	for rows.Next() {
+		run, err := mapImportRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// importRunOwner stores the runs nobody owns with a NULL owner.
+func importRunOwner(owner string) sql.NullString {
+	return sql.NullString{String: owner, Valid: owner != ""}
+}
+
+type postgresImportRunRepository struct {
+	client *postgresClient
+}
+
+func NewPostgresImportRunRepository(postgresClient *postgresClient) *postgresImportRunRepository {
+	return &postgresImportRunRepository{client: postgresClient}
+}
+
+func (r *postgresImportRunRepository) CreateImportRun(ctx context.Context, run *repositories.ImportRun) error {
+	var options sql.NullString
+	if run.Options != nil {
+		payload, err := json.Marshal(run.Options)
+		if err != nil {
+			return err
+		}
+		options = sql.NullString{String: string(payload), Valid: true}
+	}
+	return psql.Insert("import_runs").
+		Columns("source_path", "checksum", "status", "options", "owner").
+		Values(run.SourcePath, run.Checksum, string(run.Status), options, importRunOwner(run.Owner)).
+		Suffix("RETURNING id, started_at, updated_at").
+		RunWith(r.client.runner(ctx)).
+		QueryRowContext(ctx).
+		Scan(&run.ID, &run.StartedAt, &run.UpdatedAt)
+}
+
+func (r *postgresImportRunRepository) SaveImportRun(ctx context.Context, run *repositories.ImportRun) error {
+	rowErrors := run.RowErrors
+	if rowErrors == nil {
+		rowErrors = []repositories.RowError{}
+	}
+	payload, err := json.Marshal(rowErrors)
+	if err != nil {
+		return err
+	}
+	run.UpdatedAt = time.Now()
+	result, err := psql.Update("import_runs").
+		SetMap(map[string]interface{}{
+			"status":           string(run.Status),
+			"committed_offset": run.Offset,
+			"records":          run.Records,
+			"created":          run.Created,
+			"restored":         run.Restored,
+			"updated":          run.Updated,
+			"unchanged":        run.Unchanged,
+			"invalid":          run.Invalid,
+			"failed":           run.Failed,
+			"archived":         run.Archived,
+			"error":            sql.NullString{String: run.Error, Valid: run.Error != ""},
+			"row_errors":       string(payload),
+			"updated_at":       run.UpdatedAt,
+			"finished_at":      run.FinishedAt,
+		}).
+		Where(sq.Eq{"id": run.ID}).
+		Where("owner IS NOT DISTINCT FROM ?", importRunOwner(run.Owner)).
+		RunWith(r.client.runner(ctx)).
+		ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return coreerr.ErrImportRunLost
+	}
+	return nil
+}
+
+func (r *postgresImportRunRepository) getImportRun(ctx context.Context, filter sq.Eq) (*repositories.ImportRun, error) {
+	query := psql.Select(importRunColumns...).From("import_runs").Where(filter).OrderBy("id DESC").Limit(1)
+	run, err := mapImportRun(query.RunWith(r.client.runner(ctx)).QueryRowContext(ctx))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return run, err
+}
+
+func (r *postgresImportRunRepository) GetImportRun(ctx context.Context, id int64) (*repositories.ImportRun, error) {
+	return r.getImportRun(ctx, sq.Eq{"id": id})
+}
+
+func (r *postgresImportRunRepository) GetLastImportRun(ctx context.Context, sourcePath string) (*repositories.ImportRun, error) {
+	return r.getImportRun(ctx, sq.Eq{"source_path": sourcePath})
+}
+
+func (r *postgresImportRunRepository) GetImportRuns(ctx context.Context, limit int) ([]*repositories.ImportRun, error) {
+	query := psql.Select(importRunColumns...).From("import_runs").OrderBy("id DESC").Limit(uint64(limit))
+	rows, err := query.RunWith(r.client.runner(ctx)).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapImportRuns(rows)
+}
+
+func (r *postgresImportRunRepository) ClaimImportRun(ctx context.Context, id int64, owner string, staleBefore time.Time) (bool, error) {
+	// A concurrent claim waits for the row lock and then sees the fresh updated_at, so only one caller wins.
+	result, err := r.client.runner(ctx).ExecContext(ctx, `UPDATE import_runs SET status = 'running', error = NULL, finished_at = NULL, updated_at = now(), owner = $3
+WHERE id = $1 AND status <> 'completed' AND (status NOT IN ('queued', 'running') OR updated_at < $2)`, id, staleBefore, importRunOwner(owner))
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (r *postgresImportRunRepository) ClaimStaleImportJobs(ctx context.Context, owner string, staleBefore time.Time) ([]*repositories.ImportRun, error) {
+	// SKIP LOCKED lets concurrent callers claim different jobs instead of waiting for each other.
+	query := fmt.Sprintf(`UPDATE import_runs SET updated_at = now(), owner = $2
+WHERE id IN (
+  SELECT id FROM import_runs
+  WHERE %s AND NOT cancel_requested AND updated_at < $1 AND owner IS DISTINCT FROM $2
+  ORDER BY id
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING %s`, unfinishedImportJob, strings.Join(importRunColumns, ", "))
+	rows, err := r.client.runner(ctx).QueryContext(ctx, query, staleBefore, owner)
+	if err != nil {
+		return nil, err
+	}
+	return mapImportRuns(rows)
+}
+
+func (r *postgresImportRunRepository) HeartbeatImportRuns(ctx context.Context, owner string, ids ...int64) (map[int64]bool, error) {
+	cancelRequested := make(map[int64]bool, len(ids))
+	if len(ids) == 0 {
+		return cancelRequested, nil
+	}
+	rows, err := r.client.runner(ctx).QueryContext(ctx, "UPDATE import_runs SET updated_at = now() WHERE id = ANY($1) AND owner = $2 RETURNING id, cancel_requested", pq.Array(ids), owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var cancel bool
+		if err := rows.Scan(&id, &cancel); err != nil {
+			return nil, err
+		}
+		cancelRequested[id] = cancel
+	}
+	return cancelRequested, rows.Err()
+}
+
+func (r *postgresImportRunRepository) RequestImportRunCancel(ctx context.Context, id int64) (bool, error) {
+	result, err := r.client.runner(ctx).ExecContext(ctx, `UPDATE import_runs SET cancel_requested = true,
+  status = CASE WHEN status = 'queued' THEN 'cancelled' ELSE status END,
+  finished_at = CASE WHEN status = 'queued' THEN now() ELSE finished_at END,
+  updated_at = now()
+WHERE id = $1 AND `+unfinishedImportJob, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
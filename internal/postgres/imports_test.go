@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dominikus1993/integrationtestcontainers-go"
+	coreerr "github.com/micro-eshop/catalog/pkg/core/error"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportRuns(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	ctx := context.Background()
+	postgres, err := integrationtestcontainers.StartPostgreSqlContainer(ctx, integrationtestcontainers.DefaultPostgresContainerConfiguration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer postgres.Terminate(ctx)
+	db, err := NewPostgresClient(ctx, postgres.ConnectionString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close(ctx)
+	migrateUp(ctx, t, db)
+	repository := NewPostgresImportRunRepository(db)
+
+	missing, err := repository.GetLastImportRun(ctx, "products.csv")
+	assert.Nil(t, err)
+	assert.Nil(t, missing)
+
+	first := &repositories.ImportRun{SourcePath: "products.csv", Checksum: "a", Status: repositories.ImportRunRunning}
+	assert.Nil(t, repository.CreateImportRun(ctx, first))
+	second := &repositories.ImportRun{SourcePath: "products.csv", Checksum: "b", Status: repositories.ImportRunRunning}
+	assert.Nil(t, repository.CreateImportRun(ctx, second))
+	assert.Greater(t, second.ID, first.ID)
+
+	finishedAt := time.Now()
+	second.Status = repositories.ImportRunFailed
+	second.Offset = 42
+	second.Records = 41
+	second.Created = 40
+	second.Invalid = 1
+	second.Error = "can't read products source"
+	second.FinishedAt = &finishedAt
+	assert.Nil(t, repository.SaveImportRun(ctx, second))
+
+	last, err := repository.GetLastImportRun(ctx, "products.csv")
+	assert.Nil(t, err)
+	assert.Equal(t, second.ID, last.ID)
+	assert.Equal(t, repositories.ImportRunFailed, last.Status)
+	assert.Equal(t, 42, last.Offset)
+	assert.Equal(t, 40, last.Created)
+	assert.Equal(t, "can't read products source", last.Error)
+	assert.NotNil(t, last.FinishedAt)
+
+	runs, err := repository.GetImportRuns(ctx, 10)
+	assert.Nil(t, err)
+	assert.Len(t, runs, 2)
+	assert.Equal(t, second.ID, runs[0].ID)
+	assert.Equal(t, "", runs[1].Error)
+	assert.Nil(t, runs[1].FinishedAt)
+
+	t.Run("claiming a run to resume", func(t *testing.T) {
+		claimed, err := repository.ClaimImportRun(ctx, first.ID, "cli-1", time.Now().Add(-time.Minute))
+		assert.Nil(t, err)
+		assert.False(t, claimed)
+		claimed, err = repository.ClaimImportRun(ctx, second.ID, "cli-1", time.Now().Add(-time.Minute))
+		assert.Nil(t, err)
+		assert.True(t, claimed)
+		claimed, err = repository.ClaimImportRun(ctx, second.ID, "cli-1", time.Now().Add(-time.Minute))
+		assert.Nil(t, err)
+		assert.False(t, claimed)
+	})
+
+	t.Run("import jobs", func(t *testing.T) {
+		options := &repositories.ImportJobOptions{Format: "json", Sync: true, MaxErrorRate: 5}
+		job := &repositories.ImportRun{SourcePath: "upload.json", Checksum: "c", Status: repositories.ImportRunQueued, Options: options, Owner: "api-1"}
+		assert.Nil(t, repository.CreateImportRun(ctx, job))
+
+		claimed, err := repository.ClaimStaleImportJobs(ctx, "api-2", job.UpdatedAt)
+		assert.Nil(t, err)
+		assert.Empty(t, claimed)
+		claimed, err = repository.ClaimStaleImportJobs(ctx, "api-1", time.Now().Add(time.Minute))
+		assert.Nil(t, err)
+		assert.Empty(t, claimed)
+		claimed, err = repository.ClaimStaleImportJobs(ctx, "api-2", time.Now().Add(time.Minute))
+		assert.Nil(t, err)
+		assert.Len(t, claimed, 1)
+		assert.Equal(t, options, claimed[0].Options)
+		assert.Equal(t, "api-2", claimed[0].Owner)
+
+		job.Status = repositories.ImportRunRunning
+		job.RowErrors = []repositories.RowError{{Line: 3, ProductID: 7, Reason: "Name can't be empty"}}
+		assert.ErrorIs(t, repository.SaveImportRun(ctx, job), coreerr.ErrImportRunLost)
+		beats, err := repository.HeartbeatImportRuns(ctx, "api-1", job.ID)
+		assert.Nil(t, err)
+		assert.Empty(t, beats)
+
+		job.Owner = "api-2"
+		assert.Nil(t, repository.SaveImportRun(ctx, job))
+		beats, err = repository.HeartbeatImportRuns(ctx, "api-2", job.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, map[int64]bool{job.ID: false}, beats)
+
+		cancelled, err := repository.RequestImportRunCancel(ctx, job.ID)
+		assert.Nil(t, err)
+		assert.True(t, cancelled)
+		beats, err = repository.HeartbeatImportRuns(ctx, "api-2", job.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, map[int64]bool{job.ID: true}, beats)
+
+		saved, err := repository.GetImportRun(ctx, job.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, repositories.ImportRunRunning, saved.Status)
+		assert.Equal(t, job.RowErrors, saved.RowErrors)
+
+		cancelled, err = repository.RequestImportRunCancel(ctx, first.ID)
+		assert.Nil(t, err)
+		assert.False(t, cancelled)
+	})
+}
@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/micro-eshop/catalog/migrations"
+)
+
+// MigrationStatus describes the schema version of the database against the available migrations.
+type MigrationStatus struct {
+	Version uint
+	Dirty   bool
+	Latest  uint
+	Pending []uint
+}
+
+func migrationSource(path string) (string, source.Driver, error) {
+	if path != "" {
+		driver, err := (&file.File{}).Open("file://" + path)
+		return "file", driver, err
+	}
+	driver, err := iofs.New(migrations.FS, ".")
+	return "iofs", driver, err
+}
+
+func sourceVersions(driver source.Driver) ([]uint, error) {
+	version, err := driver.First()
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	versions := []uint{version}
+	for {
+		version, err = driver.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return versions, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+}
+
+type migrator struct {
+	m      *migrate.Migrate
+	source source.Driver
+}
+
+// NewMigrator migrates the schema over a dedicated connection of the client, so closing the migrator leaves the client open.
+// An empty migrationsPath uses the migrations embedded in the binary.
+func NewMigrator(ctx context.Context, client *postgresClient, migrationsPath string) (*migrator, error) {
+	sourceName, sourceDriver, err := migrationSource(migrationsPath)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := client.db.Conn(ctx)
+	if err != nil {
+		sourceDriver.Close()
+		return nil, err
+	}
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
+	if err != nil {
+		conn.Close()
+		sourceDriver.Close()
+		return nil, err
+	}
+	m, err := migrate.NewWithInstance(sourceName, sourceDriver, "postgresql", driver)
+	if err != nil {
+		driver.Close()
+		sourceDriver.Close()
+		return nil, err
+	}
+	return &migrator{m: m, source: sourceDriver}, nil
+}
+
+func ignoreNoChange(err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+	return err
+}
+
+func (m *migrator) Up() error {
+	return ignoreNoChange(m.m.Up())
+}
+
+// Down rolls back the given number of applied migrations.
+func (m *migrator) Down(steps int) error {
+	return ignoreNoChange(m.m.Steps(-steps))
+}
+
+// Goto migrates up or down to the given version.
+func (m *migrator) Goto(version uint) error {
+	return ignoreNoChange(m.m.Migrate(version))
+}
+
+// Force sets the version without running migrations and clears the dirty flag, e.g. after a failed migration was fixed by hand.
+// Version -1 means no migration is applied.
+func (m *migrator) Force(version int) error {
+	return m.m.Force(version)
+}
+
+func (m *migrator) Status() (*MigrationStatus, error) {
+	version, dirty, err := m.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, err
+	}
+	versions, err := sourceVersions(m.source)
+	if err != nil {
+		return nil, err
+	}
+	status := &MigrationStatus{Version: version, Dirty: dirty, Pending: make([]uint, 0)}
+	for _, v := range versions {
+		if v > version {
+			status.Pending = append(status.Pending, v)
+		}
+	}
+	if len(versions) > 0 {
+		status.Latest = versions[len(versions)-1]
+	}
+	return status, nil
+}
+
+func (m *migrator) Close() error {
+	sourceErr, databaseErr := m.m.Close()
+	if sourceErr != nil {
+		return sourceErr
+	}
+	return databaseErr
+}
+
+// MigrateUp applies all pending migrations, it is used by commands that migrate the schema on start.
+func MigrateUp(ctx context.Context, client *postgresClient, migrationsPath string) error {
+	migrator, err := NewMigrator(ctx, client, migrationsPath)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+	return migrator.Up()
+}
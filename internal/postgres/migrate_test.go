@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dominikus1993/integrationtestcontainers-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func migrateUp(ctx context.Context, t *testing.T, client *postgresClient) {
+	t.Helper()
+	if err := MigrateUp(ctx, client, ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEmbeddedMigrationSource(t *testing.T) {
+	name, driver, err := migrationSource("")
+	assert.Nil(t, err)
+	assert.Equal(t, "iofs", name)
+	first, err := driver.First()
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), first)
+	migration, _, err := driver.ReadUp(first)
+	assert.Nil(t, err)
+	assert.Nil(t, migration.Close())
+}
+
+func TestFileMigrationSource(t *testing.T) {
+	name, driver, err := migrationSource("../../migrations")
+	assert.Nil(t, err)
+	assert.Equal(t, "file", name)
+	first, err := driver.First()
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), first)
+}
+
+func TestSourceVersions(t *testing.T) {
+	_, driver, err := migrationSource("")
+	assert.Nil(t, err)
+	versions, err := sourceVersions(driver)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, versions)
+}
+
+func TestMigrator(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	ctx := context.Background()
+	postgres, err := integrationtestcontainers.StartPostgreSqlContainer(ctx, integrationtestcontainers.DefaultPostgresContainerConfiguration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer postgres.Terminate(ctx)
+	db, err := NewPostgresClient(ctx, postgres.ConnectionString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close(ctx)
+	migrator, err := NewMigrator(ctx, db, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer migrator.Close()
+
+	status, err := migrator.Status()
+	assert.Nil(t, err)
+	assert.Equal(t, uint(0), status.Version)
+	assert.Equal(t, []uint{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, status.Pending)
+
+	assert.Nil(t, migrator.Up())
+	status, err = migrator.Status()
+	assert.Nil(t, err)
+	assert.Equal(t, status.Latest, status.Version)
+	assert.Empty(t, status.Pending)
+
+	assert.Nil(t, migrator.Down(2))
+	status, err = migrator.Status()
+	assert.Nil(t, err)
+	assert.Equal(t, status.Latest-2, status.Version)
+
+	assert.Nil(t, migrator.Goto(status.Latest))
+	status, err = migrator.Status()
+	assert.Nil(t, err)
+	assert.Equal(t, status.Latest, status.Version)
+	assert.False(t, status.Dirty)
+}
@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+	"github.com/micro-eshop/catalog/pkg/core/services"
+)
+
+// outboxLockKey is the advisory lock held by the relay that currently drains the outbox.
+const outboxLockKey = 7301
+
+const pendingOutboxQuery = `SELECT o.id, o.aggregate_id, o.event_type, o.payload, o.attempts FROM outbox o
+WHERE o.delivered_at IS NULL AND o.next_attempt_at <= now()
+  AND NOT EXISTS (
+    SELECT 1 FROM outbox p
+    WHERE p.aggregate_id = o.aggregate_id AND p.delivered_at IS NULL AND p.id < o.id AND p.next_attempt_at > now()
+  )
+ORDER BY o.id
+LIMIT $1`
+
+// postgresOutbox stores events in the outbox table. Called with a context carrying a transaction,
+// the events are committed or rolled back together with the product change.
+type postgresOutbox struct {
+	client *postgresClient
+}
+
+func NewPostgresOutbox(postgresClient *postgresClient) *postgresOutbox {
+	return &postgresOutbox{client: postgresClient}
+}
+
+func (o *postgresOutbox) enqueue(ctx context.Context, id int, eventType string, event interface{}) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = psql.Insert("outbox").
+		Columns("aggregate_id", "event_type", "payload").
+		Values(id, eventType, string(payload)).
+		RunWith(o.client.runner(ctx)).
+		ExecContext(ctx)
+	return err
+}
+
+func (o *postgresOutbox) PublishProductCreated(ctx context.Context, event services.ProductCreated) error {
+	return o.enqueue(ctx, event.ID, services.ProductCreatedEventType, event)
+}
+
+func (o *postgresOutbox) PublishProductUpdated(ctx context.Context, event services.ProductUpdated) error {
+	return o.enqueue(ctx, event.ID, services.ProductUpdatedEventType, event)
+}
+
+func (o *postgresOutbox) PublishProductDeleted(ctx context.Context, event services.ProductDeleted) error {
+	return o.enqueue(ctx, event.ID, services.ProductDeletedEventType, event)
+}
+
+func (o *postgresOutbox) PublishProductPriceChanged(ctx context.Context, event services.ProductPriceChanged) error {
+	return o.enqueue(ctx, event.ID, services.ProductPriceChangedEventType, event)
+}
+
+func (o *postgresOutbox) TryLock(ctx context.Context) (bool, error) {
+	var locked bool
+	err := o.client.runner(ctx).QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock($1)", outboxLockKey).Scan(&locked)
+	return locked, err
+}
+
+func (o *postgresOutbox) GetPending(ctx context.Context, limit int) ([]*repositories.OutboxMessage, error) {
+	rows, err := o.client.runner(ctx).QueryContext(ctx, pendingOutboxQuery, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	messages := make([]*repositories.OutboxMessage, 0)
+	for rows.Next() {
+		var message repositories.OutboxMessage
+		var aggregateID int
+		if err := rows.Scan(&message.ID, &aggregateID, &message.Type, &message.Payload, &message.Attempts); err != nil {
+			return nil, err
+		}
+		message.AggregateID = model.ProductId(aggregateID)
+		messages = append(messages, &message)
+	}
+	return messages, rows.Err()
+}
+
+func (o *postgresOutbox) Lease(ctx context.Context, until time.Time, ids ...int64) error {
+	_, err := psql.Update("outbox").
+		Set("next_attempt_at", until).
+		Where(sq.Eq{"id": ids, "delivered_at": nil}).
+		RunWith(o.client.runner(ctx)).
+		ExecContext(ctx)
+	return err
+}
+
+func (o *postgresOutbox) MarkDelivered(ctx context.Context, id int64) error {
+	_, err := psql.Update("outbox").
+		Set("delivered_at", sq.Expr("now()")).
+		Set("attempts", sq.Expr("attempts + 1")).
+		Set("last_error", nil).
+		Where(sq.Eq{"id": id}).
+		RunWith(o.client.runner(ctx)).
+		ExecContext(ctx)
+	return err
+}
+
+func (o *postgresOutbox) MarkFailed(ctx context.Context, id int64, reason string, nextAttemptAt time.Time) error {
+	_, err := psql.Update("outbox").
+		Set("attempts", sq.Expr("attempts + 1")).
+		Set("last_error", reason).
+		Set("next_attempt_at", nextAttemptAt).
+		Where(sq.Eq{"id": id}).
+		RunWith(o.client.runner(ctx)).
+		ExecContext(ctx)
+	return err
+}
@@ -7,21 +7,22 @@ import (
 	"time"
 
 	"github.com/hashicorp/go-multierror"
+	coreerr "github.com/micro-eshop/catalog/pkg/core/error"
 	"github.com/micro-eshop/catalog/pkg/core/model"
 	"github.com/micro-eshop/catalog/pkg/core/repositories"
-	log "github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel"
 
 	sq "github.com/Masterminds/squirrel"
-	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/github"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/uptrace/opentelemetry-go-extra/otelsql"
 )
 
 var psql = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
+const productCategoryColumn = "(SELECT c.name FROM product_categories pc JOIN categories c ON c.id = pc.category_id WHERE pc.product_id = products.id ORDER BY c.id LIMIT 1) AS category"
+
+var productColumns = []string{"id", "brand", "name", "description", "price", "promotion_price", productCategoryColumn}
+
 type postgresProduct struct {
 	ProductID      int             `pg:"id"`
 	Name           string          `pg:"name"`
@@ -29,6 +30,7 @@ type postgresProduct struct {
 	Description    string          `pg:"description"`
 	Price          float64         `pg:"price"`
 	PromotionPrice sql.NullFloat64 `pg:"promotion_price"`
+	Category       sql.NullString  `pg:"category"`
 }
 
 func (p postgresProduct) getPromotionPrice() *float64 {
@@ -53,22 +55,51 @@ func newNullFloat64(s *float64) sql.NullFloat64 {
 }
 
 func newPostgresProduct(product *model.Product) *postgresProduct {
-	return &postgresProduct{ProductID: int(product.ID), Name: product.Name, Brand: product.Brand, Description: product.Description, Price: product.Price, PromotionPrice: newNullFloat64(product.PromotionPrice)}
+	return &postgresProduct{ProductID: int(product.ID), Name: product.Name, Brand: product.Brand, Description: product.Description, Price: product.Price, PromotionPrice: newNullFloat64(product.PromotionPrice), Category: sql.NullString{String: product.Category, Valid: product.Category != ""}}
 }
 
 func (p postgresProduct) toProduct() *model.Product {
-	return &model.Product{ID: model.ProductId(p.ProductID), Name: p.Name, Brand: p.Brand, Description: p.Description, Price: p.Price, PromotionPrice: p.getPromotionPrice()}
+	return &model.Product{ID: model.ProductId(p.ProductID), Name: p.Name, Brand: p.Brand, Description: p.Description, Price: p.Price, PromotionPrice: p.getPromotionPrice(), Category: p.Category.String}
+}
+
+func (p *postgresProduct) scanDest() []interface{} {
+	return []interface{}{&p.ProductID, &p.Brand, &p.Name, &p.Description, &p.Price, &p.PromotionPrice, &p.Category}
 }
 
 func mapProduct(scanner sq.RowScanner) (*postgresProduct, error) {
 	var dbProduct postgresProduct
-	err := scanner.Scan(&dbProduct.ProductID, &dbProduct.Brand, &dbProduct.Name, &dbProduct.Description, &dbProduct.Price, &dbProduct.PromotionPrice)
+	err := scanner.Scan(dbProduct.scanDest()...)
 	if err != nil {
 		return nil, err
 	}
 	return &dbProduct, nil
 }
 
+func mapProducts(rows *sql.Rows) ([]*model.Product, error) {
+	defer rows.Close()
+	var err error
+	products := make([]*model.Product, 0)
+	for rows.Next() {
+		product, nerr := mapProduct(rows)
+		if nerr != nil {
+			err = multierror.Append(err, nerr)
+			continue
+		}
+		products = append(products, product.toProduct())
+	}
+	if rerr := rows.Err(); rerr != nil {
+		err = multierror.Append(err, rerr)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	if len(products) == 0 {
+		return nil, nil
+	}
+	return products, nil
+}
+
 func mapIds(ids []model.ProductId) []int {
 	result := make([]int, len(ids))
 	for i, id := range ids {
@@ -81,24 +112,7 @@ type postgresClient struct {
 	db *sql.DB
 }
 
-func createSchema(db *sql.DB) error {
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
-	if err != nil {
-		return err
-	}
-	m, err := migrate.NewWithDatabaseInstance(
-		"github://micro-eshop/catalog/migrations#main",
-		"postgresql", driver)
-	if err != nil {
-		return err
-	}
-	err = m.Up() // or m.Step(2) if you want to explicitly set the number of migrations to run
-	if err != nil && err != migrate.ErrNoChange {
-		return err
-	}
-	return nil
-}
-
+// NewPostgresClient opens the connection pool. It does not touch the schema, use NewMigrator for that.
 func NewPostgresClient(ctx context.Context, connectionString string) (*postgresClient, error) {
 	db, err := otelsql.Open("postgres", connectionString, otelsql.WithTracerProvider(otel.GetTracerProvider()))
 	db.SetConnMaxLifetime(time.Minute * 5)
@@ -111,11 +125,6 @@ func NewPostgresClient(ctx context.Context, connectionString string) (*postgresC
 	// 	fmt.Println("Error pinging database: ", err)
 	// 	return nil, err
 	// }
-	err = createSchema(db)
-	if err != nil {
-		fmt.Println("Error creating schema: ", err)
-		return nil, err
-	}
 	return &postgresClient{db: db}, nil
 }
 
@@ -134,8 +143,8 @@ func NewPostgresCatalogRepository(postgresClient *postgresClient) *postgresCatal
 }
 
 func (r *postgresCatalogRepository) GetProductById(ctx context.Context, id model.ProductId) (*model.Product, error) {
-	query := psql.Select("id", "brand", "name", "description", "price", "promotion_price").From("products").Where(sq.Eq{"id": int(id)})
-	row := query.RunWith(r.client.db).QueryRowContext(ctx)
+	query := psql.Select(productColumns...).From("products").Where(sq.Eq{"id": int(id), "archived_at": nil})
+	row := query.RunWith(r.client.runner(ctx)).QueryRowContext(ctx)
 	product, err := mapProduct(row)
 
 	if err == sql.ErrNoRows {
@@ -149,77 +158,161 @@ func (r *postgresCatalogRepository) GetProductById(ctx context.Context, id model
 }
 
 func (r *postgresCatalogRepository) GetProductByIds(ctx context.Context, ids ...model.ProductId) ([]*model.Product, error) {
-	query := psql.Select("id", "brand", "name", "description", "price", "promotion_price").From("products").Where(sq.Eq{"id": mapIds(ids)})
-	rows, err := query.RunWith(r.client.db).QueryContext(ctx)
+	query := psql.Select(productColumns...).From("products").Where(sq.Eq{"id": mapIds(ids), "archived_at": nil}).OrderBy("id")
+	rows, err := query.RunWith(r.client.runner(ctx)).QueryContext(ctx)
 	if err != nil {
 		return nil, err
 	}
-	products := make([]*model.Product, 0)
-	for rows.Next() {
-		product, nerr := mapProduct(rows)
-		if nerr != nil {
-			err = multierror.Append(nerr)
+	return mapProducts(rows)
+}
+
+// Insert stores the product and links it to its brand and category. The brand is resolved by its normalized name,
+// so product.Brand is replaced with the canonical brand name. An archived product is not found by readers, so inserting
+// its id restores it with the new fields.
+func (r *postgresCatalogRepository) Insert(ctx context.Context, product *model.Product) error {
+	dbProduct := newPostgresProduct(product)
+	err := r.client.transaction(ctx, func(tx *sql.Tx) error {
+		brandID, brand, err := resolveBrand(ctx, tx, dbProduct.Brand)
+		if err != nil {
+			return err
 		}
-		products = append(products, product.toProduct())
-	}
+		dbProduct.Brand = brand
 
+		result, err := psql.Insert("products").
+			Columns("id", "brand", "brand_id", "name", "description", "price", "promotion_price", "content_hash").
+			Values(dbProduct.ProductID, dbProduct.Brand, brandID, dbProduct.Name, dbProduct.Description, dbProduct.Price, dbProduct.PromotionPrice, model.ContentHash(product)).
+			Suffix(`ON CONFLICT (id) DO UPDATE SET brand = EXCLUDED.brand, brand_id = EXCLUDED.brand_id, name = EXCLUDED.name,
+				description = EXCLUDED.description, price = EXCLUDED.price, promotion_price = EXCLUDED.promotion_price,
+				content_hash = EXCLUDED.content_hash, archived_at = NULL
+				WHERE products.archived_at IS NOT NULL`).
+			RunWith(tx).
+			ExecContext(ctx)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return coreerr.ErrProductAlreadyExists
+		}
+
+		return setProductCategory(ctx, tx, dbProduct.ProductID, dbProduct.Category.String)
+	})
 	if err != nil {
-		return nil, err
-	}
-	if len(products) == 0 {
-		return nil, nil
+		return err
 	}
-	return products, nil
+	product.Brand = dbProduct.Brand
+	return nil
 }
 
-func (r *postgresCatalogRepository) Search(ctx context.Context, params repositories.ProductSearchParams) ([]*model.Product, error) {
-	query := psql.Select("id", "brand", "name", "description", "price", "promotion_price").From("products")
-	if params.Name != "" {
-		query = query.Where(sq.Like{"name": "%" + params.Name + "%"})
-	}
-	if params.Brand != "" {
-		query = query.Where(sq.Like{"brand": "%" + params.Brand + "%"})
+// Update replaces all fields of an existing product. Like Insert, it replaces product.Brand with the canonical brand name.
+func (r *postgresCatalogRepository) Update(ctx context.Context, product *model.Product) error {
+	dbProduct := newPostgresProduct(product)
+	err := r.client.transaction(ctx, func(tx *sql.Tx) error {
+		brandID, brand, err := resolveBrand(ctx, tx, dbProduct.Brand)
+		if err != nil {
+			return err
+		}
+		dbProduct.Brand = brand
+
+		result, err := psql.Update("products").
+			Set("brand", dbProduct.Brand).
+			Set("brand_id", brandID).
+			Set("name", dbProduct.Name).
+			Set("description", dbProduct.Description).
+			Set("price", dbProduct.Price).
+			Set("promotion_price", dbProduct.PromotionPrice).
+			Set("content_hash", model.ContentHash(product)).
+			Where(sq.Eq{"id": dbProduct.ProductID, "archived_at": nil}).
+			RunWith(tx).
+			ExecContext(ctx)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return coreerr.ErrProductNotFound
+		}
+
+		return setProductCategory(ctx, tx, dbProduct.ProductID, dbProduct.Category.String)
+	})
+	if err != nil {
+		return err
 	}
-	if params.PriceFrom != 0 {
-		query = query.Where(sq.Gt{"price": params.PriceFrom})
+	product.Brand = dbProduct.Brand
+	return nil
+}
+
+func (r *postgresCatalogRepository) Delete(ctx context.Context, id model.ProductId) error {
+	result, err := psql.Delete("products").Where(sq.Eq{"id": int(id), "archived_at": nil}).RunWith(r.client.runner(ctx)).ExecContext(ctx)
+	if err != nil {
+		return err
 	}
-	if params.PriceTo != 0 {
-		query = query.Where(sq.Lt{"price": params.PriceTo})
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
 	}
-	if params.InPromotion {
-		query = query.Where(sq.NotEq{"promotion_price": nil})
+	if affected == 0 {
+		return coreerr.ErrProductNotFound
 	}
-	rows, err := query.RunWith(r.client.db).QueryContext(ctx)
+	return nil
+}
+
+func (r *postgresCatalogRepository) GetStoredProducts(ctx context.Context, ids ...model.ProductId) (map[model.ProductId]*repositories.StoredProduct, error) {
+	columns := append(append([]string{}, productColumns...), "content_hash", "archived_at IS NOT NULL")
+	rows, err := psql.Select(columns...).From("products").
+		Where("id = ANY(?)", pq.Array(mapIds(ids))).
+		RunWith(r.client.runner(ctx)).
+		QueryContext(ctx)
 	if err != nil {
 		return nil, err
 	}
-	products := make([]*model.Product, 0)
+	defer rows.Close()
+	products := make(map[model.ProductId]*repositories.StoredProduct, len(ids))
 	for rows.Next() {
-		product, nerr := mapProduct(rows)
-		if nerr != nil {
-			err = multierror.Append(nerr)
+		var dbProduct postgresProduct
+		var contentHash sql.NullString
+		var archived bool
+		if err := rows.Scan(append(dbProduct.scanDest(), &contentHash, &archived)...); err != nil {
+			return nil, err
 		}
-		products = append(products, product.toProduct())
+		product := dbProduct.toProduct()
+		products[product.ID] = &repositories.StoredProduct{Product: product, ContentHash: contentHash.String, Archived: archived}
 	}
+	return products, rows.Err()
+}
 
+func (r *postgresCatalogRepository) GetProductIds(ctx context.Context) ([]model.ProductId, error) {
+	rows, err := psql.Select("id").From("products").Where(sq.Eq{"archived_at": nil}).RunWith(r.client.runner(ctx)).QueryContext(ctx)
 	if err != nil {
 		return nil, err
 	}
-	if len(products) == 0 {
-		return nil, nil
+	defer rows.Close()
+	ids := make([]model.ProductId, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, model.ProductId(id))
 	}
-	return products, nil
+	return ids, rows.Err()
 }
 
-func (r *postgresCatalogRepository) Insert(ctx context.Context, product *model.Product) error {
-	dbProduct := newPostgresProduct(product)
-	log.Println("Inserting product: ", dbProduct)
-	query := psql.Insert("products").
-		Columns("id", "brand", "name", "description", "price", "promotion_price").
-		Values(dbProduct.ProductID, dbProduct.Brand, dbProduct.Name, dbProduct.Description, dbProduct.Price, dbProduct.PromotionPrice).
-		RunWith(r.client.db)
-
-	_, err := query.Exec()
-
+func (r *postgresCatalogRepository) Archive(ctx context.Context, ids ...model.ProductId) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := psql.Update("products").
+		Set("archived_at", sq.Expr("now()")).
+		Where("id = ANY(?)", pq.Array(mapIds(ids))).
+		Where(sq.Eq{"archived_at": nil}).
+		RunWith(r.client.runner(ctx)).
+		ExecContext(ctx)
 	return err
 }
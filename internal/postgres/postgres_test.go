@@ -5,7 +5,9 @@ import (
 	"testing"
 
 	"github.com/dominikus1993/integrationtestcontainers-go"
+	coreerr "github.com/micro-eshop/catalog/pkg/core/error"
 	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -54,6 +56,7 @@ func TestGetProductById(t *testing.T) {
 		t.Fatal(err)
 	}
 	defer db.Close(ctx)
+	migrateUp(ctx, t, db)
 	repository := NewPostgresCatalogRepository(db)
 	t.Run("when product does not exist", func(t *testing.T) {
 		product, _ := repository.GetProductById(ctx, model.ProductId(1))
@@ -93,6 +96,7 @@ func TestGetProductByIds(t *testing.T) {
 		t.Fatal(err)
 	}
 	defer db.Close(ctx)
+	migrateUp(ctx, t, db)
 	repository := NewPostgresCatalogRepository(db)
 	t.Run("when product does not exist", func(t *testing.T) {
 		product, _ := repository.GetProductByIds(ctx, model.ProductId(1), model.ProductId(2))
@@ -120,3 +124,124 @@ func TestGetProductByIds(t *testing.T) {
 		assert.Contains(t, products, product2)
 	})
 }
+
+func TestSearch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	ctx := context.Background()
+	postgres, err := integrationtestcontainers.StartPostgreSqlContainer(ctx, integrationtestcontainers.DefaultPostgresContainerConfiguration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer postgres.Terminate(ctx)
+	db, err := NewPostgresClient(ctx, postgres.ConnectionString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close(ctx)
+	migrateUp(ctx, t, db)
+	repository := NewPostgresCatalogRepository(db)
+	for i := 1; i <= 5; i++ {
+		err := repository.Insert(ctx, model.NewProduct(model.ProductId(i), "name", "brand", "description", float64(10-i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("when paging by price", func(t *testing.T) {
+		params := repositories.ProductSearchParams{PageSize: 2, SortBy: repositories.SortByPrice, SortDirection: repositories.SortAsc}
+		ids := make([]model.ProductId, 0)
+		for {
+			page, err := repository.Search(ctx, params)
+			assert.Nil(t, err)
+			assert.Equal(t, 5, page.Total)
+			for _, product := range page.Products {
+				ids = append(ids, product.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			params.Cursor = page.NextCursor
+		}
+		assert.Equal(t, []model.ProductId{5, 4, 3, 2, 1}, ids)
+	})
+
+	t.Run("when filtering by price range", func(t *testing.T) {
+		page, err := repository.Search(ctx, repositories.ProductSearchParams{PriceFrom: 6, PriceTo: 8})
+		assert.Nil(t, err)
+		assert.Equal(t, 3, page.Total)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("when computing facets", func(t *testing.T) {
+		facets, err := repository.Facets(ctx, repositories.ProductSearchParams{Brands: []string{"brand"}, PriceFrom: 6, PriceTo: 8})
+		assert.Nil(t, err)
+		assert.Equal(t, []repositories.FacetCount{{Value: "brand", Count: 3}}, facets.Brands)
+		assert.Equal(t, 5, facets.PriceBuckets[0].Count)
+		assert.Equal(t, 0, facets.Promotion.InPromotion)
+		assert.Equal(t, 3, facets.Promotion.NotInPromotion)
+	})
+}
+
+func TestWriteProduct(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	ctx := context.Background()
+	postgres, err := integrationtestcontainers.StartPostgreSqlContainer(ctx, integrationtestcontainers.DefaultPostgresContainerConfiguration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer postgres.Terminate(ctx)
+	db, err := NewPostgresClient(ctx, postgres.ConnectionString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close(ctx)
+	migrateUp(ctx, t, db)
+	repository := NewPostgresCatalogRepository(db)
+	product := model.NewProduct(model.ProductId(1), "name", "brand", "description", 1.0)
+	if err := repository.Insert(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("when product already exists", func(t *testing.T) {
+		err := repository.Insert(ctx, product)
+		assert.ErrorIs(t, err, coreerr.ErrProductAlreadyExists)
+	})
+
+	t.Run("when updating existing product", func(t *testing.T) {
+		updated := model.NewPromotionalProduct(product.ID, "new name", "brand", "description", 2.0, 1.5)
+		err := repository.Update(ctx, updated)
+		assert.Nil(t, err)
+		dbproduct, err := repository.GetProductById(ctx, product.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, updated, dbproduct)
+	})
+
+	t.Run("when updating missing product", func(t *testing.T) {
+		err := repository.Update(ctx, model.NewProduct(model.ProductId(2), "name", "brand", "description", 1.0))
+		assert.ErrorIs(t, err, coreerr.ErrProductNotFound)
+	})
+
+	t.Run("when deleting product", func(t *testing.T) {
+		err := repository.Delete(ctx, product.ID)
+		assert.Nil(t, err)
+		err = repository.Delete(ctx, product.ID)
+		assert.ErrorIs(t, err, coreerr.ErrProductNotFound)
+	})
+
+	t.Run("when product is archived", func(t *testing.T) {
+		archived := model.NewProduct(model.ProductId(3), "name", "brand", "description", 1.0)
+		assert.Nil(t, repository.Insert(ctx, archived))
+		assert.Nil(t, repository.Archive(ctx, archived.ID))
+		assert.ErrorIs(t, repository.Delete(ctx, archived.ID), coreerr.ErrProductNotFound)
+
+		restored := model.NewProduct(archived.ID, "new name", "brand", "description", 2.0)
+		assert.Nil(t, repository.Insert(ctx, restored))
+		dbproduct, err := repository.GetProductById(ctx, archived.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, restored, dbproduct)
+	})
+}
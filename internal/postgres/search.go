@@ -0,0 +1,199 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/hashicorp/go-multierror"
+	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+)
+
+const textSearchConfig = "english"
+
+// prefixTsQuery turns free text into a tsquery where every word has to match as a prefix, e.g. "black hood" -> "black:* & hood:*".
+// Anything that is not a letter or a digit is treated as a separator, so user input can't inject tsquery operators.
+func prefixTsQuery(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+func rankExpression(params repositories.ProductSearchParams) sq.Sqlizer {
+	return sq.Expr(fmt.Sprintf("ts_rank(search_vector, to_tsquery('%s', ?))::float8", textSearchConfig), prefixTsQuery(params.Query))
+}
+
+func sortExpression(params repositories.ProductSearchParams) sq.Sqlizer {
+	switch params.SortBy {
+	case repositories.SortByName:
+		return sq.Expr("name")
+	case repositories.SortByBrand:
+		return sq.Expr("coalesce(brand, '')")
+	case repositories.SortByPrice:
+		return sq.Expr("price")
+	case repositories.SortByRelevance:
+		return rankExpression(params)
+	default:
+		return sq.Expr("id")
+	}
+}
+
+func sortValue(field repositories.SortField, product *rankedProduct) interface{} {
+	switch field {
+	case repositories.SortByName:
+		return product.Name
+	case repositories.SortByBrand:
+		return product.Brand
+	case repositories.SortByPrice:
+		return product.Price
+	case repositories.SortByRelevance:
+		return product.Rank
+	default:
+		return nil
+	}
+}
+
+func applySearchFilters(query sq.SelectBuilder, params repositories.ProductSearchParams) sq.SelectBuilder {
+	query = query.Where(sq.Eq{"archived_at": nil})
+	if params.Query != "" {
+		query = query.Where(fmt.Sprintf("search_vector @@ to_tsquery('%s', ?)", textSearchConfig), prefixTsQuery(params.Query))
+	}
+	if params.Name != "" {
+		query = query.Where(sq.ILike{"name": "%" + params.Name + "%"})
+	}
+	if params.Brand != "" {
+		query = query.Where(sq.ILike{"brand": "%" + params.Brand + "%"})
+	}
+	if len(params.Brands) > 0 {
+		query = query.Where(sq.Eq{"brand": params.Brands})
+	}
+	if params.PriceFrom != 0 {
+		query = query.Where(sq.GtOrEq{"price": params.PriceFrom})
+	}
+	if params.PriceTo != 0 {
+		query = query.Where(sq.LtOrEq{"price": params.PriceTo})
+	}
+	if params.InPromotion {
+		query = query.Where(sq.NotEq{"promotion_price": nil})
+	}
+	if params.CategoryID != 0 {
+		query = query.Where(categoryProductsFilter, int(params.CategoryID))
+	}
+	return query
+}
+
+func applyCursor(query sq.SelectBuilder, params repositories.ProductSearchParams, cursor *repositories.ProductCursor) (sq.SelectBuilder, error) {
+	direction, operator := "ASC", ">"
+	if params.SortDirection == repositories.SortDesc {
+		direction, operator = "DESC", "<"
+	}
+	if params.SortBy == repositories.SortById {
+		if cursor != nil {
+			query = query.Where(fmt.Sprintf("id %s ?", operator), int(cursor.ID))
+		}
+		return query.OrderBy("id " + direction), nil
+	}
+	expression, args, err := sortExpression(params).ToSql()
+	if err != nil {
+		return query, err
+	}
+	if cursor != nil {
+		cursorArgs := append(append([]interface{}{}, args...), cursor.SortValue, int(cursor.ID))
+		query = query.Where(fmt.Sprintf("(%s, id) %s (?, ?)", expression, operator), cursorArgs...)
+	}
+	return query.OrderByClause(expression+" "+direction, args...).OrderBy("id " + direction), nil
+}
+
+type rankedProduct struct {
+	*model.Product
+	Rank float64
+}
+
+func mapRankedProducts(rows *sql.Rows, ranked bool) ([]*rankedProduct, error) {
+	defer rows.Close()
+	var err error
+	products := make([]*rankedProduct, 0)
+	for rows.Next() {
+		var dbProduct postgresProduct
+		var rank float64
+		dest := dbProduct.scanDest()
+		if ranked {
+			dest = append(dest, &rank)
+		}
+		if nerr := rows.Scan(dest...); nerr != nil {
+			err = multierror.Append(err, nerr)
+			continue
+		}
+		products = append(products, &rankedProduct{Product: dbProduct.toProduct(), Rank: rank})
+	}
+	if rerr := rows.Err(); rerr != nil {
+		err = multierror.Append(err, rerr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (r *postgresCatalogRepository) count(ctx context.Context, params repositories.ProductSearchParams) (int, error) {
+	query := applySearchFilters(psql.Select("count(*)").From("products"), params)
+	var total int
+	err := query.RunWith(r.client.runner(ctx)).QueryRowContext(ctx).Scan(&total)
+	return total, err
+}
+
+func (r *postgresCatalogRepository) Search(ctx context.Context, params repositories.ProductSearchParams) (*repositories.ProductsPage, error) {
+	params = repositories.NormalizeProductSearchParams(params)
+	cursor, err := repositories.DecodeProductCursor(params)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := r.count(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	ranked := params.Query != ""
+	query := psql.Select(productColumns...).From("products")
+	if ranked {
+		query = query.Column(rankExpression(params))
+	}
+	query, err = applyCursor(applySearchFilters(query, params), params, cursor)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := query.Limit(uint64(params.PageSize + 1)).RunWith(r.client.runner(ctx)).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	products, err := mapRankedProducts(rows, ranked)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &repositories.ProductsPage{Total: total}
+	if len(products) > params.PageSize {
+		products = products[:params.PageSize]
+		last := products[len(products)-1]
+		page.NextCursor = repositories.EncodeProductCursor(repositories.ProductCursor{
+			SortBy:        params.SortBy,
+			SortDirection: params.SortDirection,
+			SortValue:     sortValue(params.SortBy, last),
+			ID:            last.ID,
+		})
+	}
+	page.Products = make([]*model.Product, len(products))
+	for i, product := range products {
+		page.Products[i] = product.Product
+	}
+	return page, nil
+}
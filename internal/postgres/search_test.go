@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyCursorWhenSortedById(t *testing.T) {
+	params := repositories.ProductSearchParams{SortBy: repositories.SortById, SortDirection: repositories.SortAsc}
+	cursor := &repositories.ProductCursor{SortBy: repositories.SortById, SortDirection: repositories.SortAsc, ID: 10}
+	query, err := applyCursor(psql.Select("id").From("products"), params, cursor)
+	assert.Nil(t, err)
+	subject, args, err := query.ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT id FROM products WHERE id > $1 ORDER BY id ASC", subject)
+	assert.Equal(t, []interface{}{10}, args)
+}
+
+func TestApplyCursorWhenSortedByPriceDescending(t *testing.T) {
+	params := repositories.ProductSearchParams{SortBy: repositories.SortByPrice, SortDirection: repositories.SortDesc}
+	cursor := &repositories.ProductCursor{SortBy: repositories.SortByPrice, SortDirection: repositories.SortDesc, SortValue: 12.5, ID: 10}
+	query, err := applyCursor(psql.Select("id").From("products"), params, cursor)
+	assert.Nil(t, err)
+	subject, args, err := query.ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT id FROM products WHERE (price, id) < ($1, $2) ORDER BY price DESC, id DESC", subject)
+	assert.Equal(t, []interface{}{12.5, 10}, args)
+}
+
+func TestApplyCursorWhenSortedByRelevance(t *testing.T) {
+	params := repositories.ProductSearchParams{Query: "hood", SortBy: repositories.SortByRelevance, SortDirection: repositories.SortDesc}
+	cursor := &repositories.ProductCursor{SortBy: repositories.SortByRelevance, SortDirection: repositories.SortDesc, SortValue: 0.5, ID: 10}
+	query, err := applyCursor(psql.Select("id").From("products"), params, cursor)
+	assert.Nil(t, err)
+	subject, args, err := query.ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT id FROM products WHERE (ts_rank(search_vector, to_tsquery('english', $1))::float8, id) < ($2, $3) ORDER BY ts_rank(search_vector, to_tsquery('english', $4))::float8 DESC, id DESC", subject)
+	assert.Equal(t, []interface{}{"hood:*", 0.5, 10, "hood:*"}, args)
+}
+
+func TestPrefixTsQuery(t *testing.T) {
+	assert.Equal(t, "net:* & bot:* & hood:*", prefixTsQuery(".NET Bot   Hood"))
+	assert.Equal(t, "hood:*", prefixTsQuery("hood')|!"))
+	assert.Equal(t, "", prefixTsQuery(" & | "))
+}
+
+func TestApplySearchFiltersWhenBrandsAreSelected(t *testing.T) {
+	params := repositories.ProductSearchParams{Brands: []string{".NET", "Other"}, InPromotion: true}
+	subject, args, err := applySearchFilters(psql.Select("id").From("products"), params).ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT id FROM products WHERE archived_at IS NULL AND brand IN ($1,$2) AND promotion_price IS NOT NULL", subject)
+	assert.Equal(t, []interface{}{".NET", "Other"}, args)
+}
+
+func TestEscapeLike(t *testing.T) {
+	assert.Equal(t, `100\% cotton\_`, escapeLike("100% cotton_"))
+}
@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+)
+
+// Names and brands are matched by prefix or by trigram word similarity, so small typos still find a completion.
+// Both conditions are served by the trigram GIN indexes on products.name and products.brand.
+const suggestQuery = `
+(SELECT name AS value, 'name' AS kind FROM products
+  WHERE archived_at IS NULL AND ($1 <% name OR name ILIKE $2)
+  GROUP BY name
+  ORDER BY bool_or(name ILIKE $2) DESC, max(word_similarity($1, name)) DESC, name
+  LIMIT $3)
+UNION ALL
+(SELECT brand AS value, 'brand' AS kind FROM products
+  WHERE archived_at IS NULL AND ($1 <% brand OR brand ILIKE $2)
+  GROUP BY brand
+  ORDER BY bool_or(brand ILIKE $2) DESC, max(word_similarity($1, brand)) DESC, brand
+  LIMIT $3)`
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(value string) string {
+	return likeEscaper.Replace(value)
+}
+
+func (r *postgresCatalogRepository) Suggest(ctx context.Context, prefix string, limit int) (*repositories.Suggestions, error) {
+	rows, err := r.client.runner(ctx).QueryContext(ctx, suggestQuery, prefix, escapeLike(prefix)+"%", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := &repositories.Suggestions{Names: make([]string, 0), Brands: make([]string, 0)}
+	for rows.Next() {
+		var value, kind string
+		if err := rows.Scan(&value, &kind); err != nil {
+			return nil, err
+		}
+		if kind == "brand" {
+			result.Brands = append(result.Brands, value)
+		} else {
+			result.Names = append(result.Names, value)
+		}
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+type txKey struct{}
+
+func transactionFrom(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*sql.Tx)
+	return tx, ok
+}
+
+func (c *postgresClient) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := transactionFrom(ctx); ok {
+		return fn(ctx)
+	}
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (c *postgresClient) transaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return c.WithinTransaction(ctx, func(ctx context.Context) error {
+		tx, _ := transactionFrom(ctx)
+		return fn(tx)
+	})
+}
+
+// runner returns the transaction carried by ctx, or the connection pool when there is none.
+func (c *postgresClient) runner(ctx context.Context) sq.StdSqlCtx {
+	if tx, ok := transactionFrom(ctx); ok {
+		return tx
+	}
+	return c.db
+}
@@ -4,17 +4,53 @@ import (
 	"context"
 
 	jsoniter "github.com/json-iterator/go"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
 	"github.com/micro-eshop/catalog/pkg/core/services"
 	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/amqp"
 )
 
 var json = jsoniter.ConfigCompatibleWithStandardLibrary
 
-func (client *RabbitMqStreamClient) PublishProductCreated(ctx context.Context, event services.ProductCreated) error {
+// Every event is sent to the same stream, so events of one product stay ordered.
+// Consumers route by the "type" application property, which is also set as the message subject.
+func newEventMessage(eventType string, body []byte) *amqp.AMQP10 {
+	msg := amqp.NewMessage(body)
+	msg.Properties = &amqp.MessageProperties{Subject: eventType, ContentType: "application/json"}
+	msg.ApplicationProperties = map[string]interface{}{"type": eventType}
+	return msg
+}
+
+func (client *RabbitMqStreamClient) publishEvent(ctx context.Context, eventType string, event interface{}) error {
 	json, err := json.Marshal(event)
 	if err != nil {
 		return err
 	}
-	msg := amqp.NewMessage(json)
-	return client.Publish(ctx, msg)
+	return client.Publish(ctx, newEventMessage(eventType, json))
+}
+
+func (client *RabbitMqStreamClient) PublishProductCreated(ctx context.Context, event services.ProductCreated) error {
+	return client.publishEvent(ctx, services.ProductCreatedEventType, event)
+}
+
+func (client *RabbitMqStreamClient) PublishProductUpdated(ctx context.Context, event services.ProductUpdated) error {
+	return client.publishEvent(ctx, services.ProductUpdatedEventType, event)
+}
+
+func (client *RabbitMqStreamClient) PublishProductDeleted(ctx context.Context, event services.ProductDeleted) error {
+	return client.publishEvent(ctx, services.ProductDeletedEventType, event)
+}
+
+func (client *RabbitMqStreamClient) PublishProductPriceChanged(ctx context.Context, event services.ProductPriceChanged) error {
+	return client.publishEvent(ctx, services.ProductPriceChangedEventType, event)
+}
+
+// PublishMessages sends messages relayed from the outbox and waits for their confirmations. The outbox id is used as
+// the message id, so consumers can drop redeliveries.
+func (client *RabbitMqStreamClient) PublishMessages(ctx context.Context, messages []*repositories.OutboxMessage) []error {
+	msgs := make([]*amqp.AMQP10, len(messages))
+	for i, message := range messages {
+		msgs[i] = newEventMessage(message.Type, message.Payload)
+		msgs[i].Properties.MessageID = uint64(message.ID)
+	}
+	return client.PublishAll(ctx, msgs...)
 }
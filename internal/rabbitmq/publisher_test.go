@@ -0,0 +1,16 @@
+package rabbitmq
+
+import (
+	"testing"
+
+	"github.com/micro-eshop/catalog/pkg/core/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewEventMessage(t *testing.T) {
+	subject := newEventMessage(services.ProductDeletedEventType, []byte(`{"id":1}`))
+	assert.Equal(t, services.ProductDeletedEventType, subject.Properties.Subject)
+	assert.Equal(t, "application/json", subject.Properties.ContentType)
+	assert.Equal(t, services.ProductDeletedEventType, subject.ApplicationProperties["type"])
+	assert.Equal(t, [][]byte{[]byte(`{"id":1}`)}, subject.GetData())
+}
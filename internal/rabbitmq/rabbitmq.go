@@ -3,6 +3,7 @@ package rabbitmq
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/amqp"
@@ -15,9 +16,16 @@ import (
 
 const productsImportStream = "product-imported"
 
+// confirmTimeout bounds the wait for the broker to confirm a message, when ctx has no earlier deadline.
+const confirmTimeout = 30 * time.Second
+
 type RabbitMqStreamClient struct {
 	env      *stream.Environment
 	producer *stream.Producer
+	mu       sync.Mutex
+	sequence int64
+	// unconfirmed holds the channel waiting for the confirmation of every message sent, by publishing id.
+	unconfirmed map[int64]chan error
 }
 
 func NewRabbitMqStreamClient(uri string) (*RabbitMqStreamClient, error) {
@@ -38,7 +46,52 @@ func NewRabbitMqStreamClient(uri string) (*RabbitMqStreamClient, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create producer: %s", err)
 	}
-	return &RabbitMqStreamClient{env: env, producer: producer}, nil
+	client := &RabbitMqStreamClient{env: env, producer: producer, unconfirmed: make(map[int64]chan error)}
+	go client.dispatchConfirmations(producer.NotifyPublishConfirmation())
+	return client, nil
+}
+
+// dispatchConfirmations hands every confirmation of the producer to the publish waiting for it. The producer blocks
+// until its confirmations are read, so they are read for as long as the producer is open.
+func (c *RabbitMqStreamClient) dispatchConfirmations(confirmations stream.ChannelPublishConfirm) {
+	for statuses := range confirmations {
+		for _, status := range statuses {
+			var err error
+			if !status.IsConfirmed() {
+				err = status.GetError()
+				if err == nil {
+					err = fmt.Errorf("message not confirmed, code %d", status.GetErrorCode())
+				}
+			}
+			c.confirm(status.GetPublishingId(), err)
+		}
+	}
+}
+
+func (c *RabbitMqStreamClient) confirm(publishingId int64, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if confirmed, ok := c.unconfirmed[publishingId]; ok {
+		delete(c.unconfirmed, publishingId)
+		confirmed <- err
+	}
+}
+
+// expect gives the message the next publishing id and returns the channel its confirmation is sent to.
+func (c *RabbitMqStreamClient) expect(msg *amqp.AMQP10) (int64, chan error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sequence++
+	msg.SetPublishingId(c.sequence)
+	confirmed := make(chan error, 1)
+	c.unconfirmed[c.sequence] = confirmed
+	return c.sequence, confirmed
+}
+
+func (c *RabbitMqStreamClient) forget(publishingId int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.unconfirmed, publishingId)
 }
 
 func (c *RabbitMqStreamClient) Close() {
@@ -50,20 +103,51 @@ func (c *RabbitMqStreamClient) Close() {
 	}
 }
 
+// Publish sends the message and waits until the broker confirmed it.
 func (c *RabbitMqStreamClient) Publish(ctx context.Context, msg *amqp.AMQP10) error {
-	span := c.startRabbitMqSpan(ctx, c.producer.GetName(), msg)
-	defer span.End()
-	err := c.producer.Send(msg)
-	if err != nil {
-		span.RecordError(err)
-		return fmt.Errorf("failed to send message: %w", err)
+	return c.PublishAll(ctx, msg)[0]
+}
+
+// PublishAll sends the messages together and waits until the broker confirmed every one of them. It returns the error
+// of every message, in the order of the messages, nil for a confirmed message.
+func (c *RabbitMqStreamClient) PublishAll(ctx context.Context, msgs ...*amqp.AMQP10) []error {
+	ctx, cancel := context.WithTimeout(ctx, confirmTimeout)
+	defer cancel()
+	errs := make([]error, len(msgs))
+	publishingIds := make([]int64, len(msgs))
+	confirmations := make([]chan error, len(msgs))
+	spans := make([]trace.Span, len(msgs))
+	for i, msg := range msgs {
+		spans[i] = c.startRabbitMqSpan(ctx, c.producer.GetName(), msg)
+		publishingIds[i], confirmations[i] = c.expect(msg)
+		if err := c.producer.Send(msg); err != nil {
+			c.forget(publishingIds[i])
+			errs[i] = fmt.Errorf("failed to send message: %w", err)
+		}
+	}
+	for i := range msgs {
+		if errs[i] == nil {
+			select {
+			case err := <-confirmations[i]:
+				if err != nil {
+					errs[i] = fmt.Errorf("message was not confirmed: %w", err)
+				}
+			case <-ctx.Done():
+				c.forget(publishingIds[i])
+				errs[i] = fmt.Errorf("message was not confirmed: %w", ctx.Err())
+			}
+		}
+		if errs[i] != nil {
+			spans[i].RecordError(errs[i])
+		}
+		spans[i].End()
 	}
-	return nil
+	return errs
 }
 
 // xD
 
-func (publisher RabbitMqStreamClient) startRabbitMqSpan(ctx context.Context, queue string, msg *amqp.AMQP10) trace.Span {
+func (publisher *RabbitMqStreamClient) startRabbitMqSpan(ctx context.Context, queue string, msg *amqp.AMQP10) trace.Span {
 	carrier := newRabbitMqCarrier(msg)
 	propagator := otel.GetTextMapPropagator()
 	ctx = propagator.Extract(ctx, carrier)
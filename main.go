@@ -30,6 +30,9 @@ func main() {
 	subcommands.Register(subcommands.CommandsCommand(), "")
 	subcommands.Register(cmd.NewRunApiCmd(v), "")
 	subcommands.Register(&cmd.ImportProductsCmd{}, "")
+	subcommands.Register(cmd.NewRunOutboxRelayCmd(v), "")
+	subcommands.Register(cmd.NewMigrateCmd(v), "")
+	subcommands.Register(cmd.NewImportStatusCmd(v), "")
 
 	flag.Parse()
 	ctx := context.Background()
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS products_brand_trgm_idx;
+DROP INDEX IF EXISTS products_name_trgm_idx;
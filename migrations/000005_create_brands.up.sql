@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS brands (
+  id SERIAL PRIMARY KEY,
+  slug TEXT NOT NULL UNIQUE CHECK (slug <> ''),
+  name TEXT NOT NULL,
+  logo_url TEXT NULL
+);
+
+-- The slug expression mirrors model.NewBrandSlug. When several spellings share a slug the most used one becomes the brand name.
+INSERT INTO brands (slug, name)
+SELECT DISTINCT ON (slug) slug, name FROM (
+  SELECT trim(both '-' from regexp_replace(lower(trim(brand)), '[^a-z0-9]+', '-', 'g')) AS slug, trim(brand) AS name, count(*) AS products
+  FROM products
+  WHERE brand IS NOT NULL
+  GROUP BY 1, 2
+) variants
+WHERE slug <> ''
+ORDER BY slug, products DESC, name
+ON CONFLICT (slug) DO NOTHING;
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS brand_id INTEGER NULL REFERENCES brands(id);
+CREATE INDEX IF NOT EXISTS products_brand_id_idx ON products (brand_id);
+
+UPDATE products p SET brand_id = b.id, brand = b.name
+FROM brands b
+WHERE b.slug = trim(both '-' from regexp_replace(lower(trim(p.brand)), '[^a-z0-9]+', '-', 'g'));
@@ -0,0 +1 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS content_hash TEXT NULL;
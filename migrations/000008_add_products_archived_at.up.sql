@@ -0,0 +1 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ NULL;
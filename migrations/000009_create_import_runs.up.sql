@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS import_runs (
+  id BIGSERIAL PRIMARY KEY,
+  source_path TEXT NOT NULL,
+  checksum TEXT NOT NULL,
+  status TEXT NOT NULL,
+  committed_offset INTEGER NOT NULL DEFAULT 0,
+  records INTEGER NOT NULL DEFAULT 0,
+  created INTEGER NOT NULL DEFAULT 0,
+  restored INTEGER NOT NULL DEFAULT 0,
+  updated INTEGER NOT NULL DEFAULT 0,
+  unchanged INTEGER NOT NULL DEFAULT 0,
+  invalid INTEGER NOT NULL DEFAULT 0,
+  failed INTEGER NOT NULL DEFAULT 0,
+  archived INTEGER NOT NULL DEFAULT 0,
+  error TEXT NULL,
+  started_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  finished_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS import_runs_source_path_idx ON import_runs (source_path, id DESC);
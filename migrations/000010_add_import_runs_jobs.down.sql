@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS import_runs_pending_jobs_idx;
+ALTER TABLE import_runs DROP COLUMN IF EXISTS owner;
+ALTER TABLE import_runs DROP COLUMN IF EXISTS cancel_requested;
+ALTER TABLE import_runs DROP COLUMN IF EXISTS row_errors;
+ALTER TABLE import_runs DROP COLUMN IF EXISTS options;
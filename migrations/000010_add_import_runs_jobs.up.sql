@@ -0,0 +1,6 @@
+ALTER TABLE import_runs ADD COLUMN IF NOT EXISTS options JSONB NULL;
+ALTER TABLE import_runs ADD COLUMN IF NOT EXISTS row_errors JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE import_runs ADD COLUMN IF NOT EXISTS cancel_requested BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE import_runs ADD COLUMN IF NOT EXISTS owner TEXT NULL;
+
+CREATE INDEX IF NOT EXISTS import_runs_pending_jobs_idx ON import_runs (updated_at) WHERE options IS NOT NULL AND status IN ('queued', 'running');
@@ -0,0 +1,7 @@
+// Package migrations embeds the SQL migrations, so the binary always runs the schema it was built with.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
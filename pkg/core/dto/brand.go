@@ -0,0 +1,14 @@
+package dto
+
+import "github.com/micro-eshop/catalog/pkg/core/model"
+
+type BrandDto struct {
+	ID      int     `json:"id"`
+	Slug    string  `json:"slug"`
+	Name    string  `json:"name"`
+	LogoURL *string `json:"logoUrl"`
+}
+
+func NewBrandDto(brand *model.Brand) *BrandDto {
+	return &BrandDto{ID: int(brand.ID), Slug: brand.Slug, Name: brand.Name, LogoURL: brand.LogoURL}
+}
@@ -1,6 +1,9 @@
 package dto
 
-import "github.com/micro-eshop/catalog/pkg/core/model"
+import (
+	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+)
 
 type ProductDto struct {
 	ID             int      `json:"id"`
@@ -9,8 +12,75 @@ type ProductDto struct {
 	Description    string   `json:"description"`
 	Price          float64  `json:"price"`
 	PromotionPrice *float64 `json:"promotionPrice"`
+	Category       string   `json:"category,omitempty"`
 }
 
 func NewProductDto(product *model.Product) *ProductDto {
-	return &ProductDto{ID: int(product.ID), Name: product.Name, Brand: product.Brand, Description: product.Description, Price: product.Price, PromotionPrice: product.PromotionPrice}
+	return &ProductDto{ID: int(product.ID), Name: product.Name, Brand: product.Brand, Description: product.Description, Price: product.Price, PromotionPrice: product.PromotionPrice, Category: product.Category}
+}
+
+func (p *ProductDto) ToProduct() *model.Product {
+	return &model.Product{ID: model.ProductId(p.ID), Name: p.Name, Brand: p.Brand, Description: p.Description, Price: p.Price, PromotionPrice: p.PromotionPrice, Category: p.Category}
+}
+
+type ProductsPageDto struct {
+	Items      []*ProductDto `json:"items"`
+	Total      int           `json:"total"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+	Facets     *FacetsDto    `json:"facets,omitempty"`
+}
+
+func NewProductsPageDto(products []*model.Product, total int, nextCursor string) *ProductsPageDto {
+	items := make([]*ProductDto, len(products))
+	for i, product := range products {
+		items[i] = NewProductDto(product)
+	}
+	return &ProductsPageDto{Items: items, Total: total, NextCursor: nextCursor}
+}
+
+type FacetCountDto struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type PriceBucketDto struct {
+	From  float64  `json:"from"`
+	To    *float64 `json:"to"`
+	Count int      `json:"count"`
+}
+
+type PromotionFacetDto struct {
+	InPromotion    int `json:"inPromotion"`
+	NotInPromotion int `json:"notInPromotion"`
+}
+
+type FacetsDto struct {
+	Brands    []FacetCountDto   `json:"brands"`
+	Prices    []PriceBucketDto  `json:"prices"`
+	Promotion PromotionFacetDto `json:"promotion"`
+}
+
+func NewFacetsDto(facets *repositories.ProductFacets) *FacetsDto {
+	brands := make([]FacetCountDto, len(facets.Brands))
+	for i, brand := range facets.Brands {
+		brands[i] = FacetCountDto{Value: brand.Value, Count: brand.Count}
+	}
+	prices := make([]PriceBucketDto, len(facets.PriceBuckets))
+	for i, bucket := range facets.PriceBuckets {
+		prices[i] = PriceBucketDto{From: bucket.From, To: bucket.To, Count: bucket.Count}
+	}
+	return &FacetsDto{
+		Brands:    brands,
+		Prices:    prices,
+		Promotion: PromotionFacetDto{InPromotion: facets.Promotion.InPromotion, NotInPromotion: facets.Promotion.NotInPromotion},
+	}
+}
+
+type SuggestionsDto struct {
+	Names  []string `json:"names"`
+	Brands []string `json:"brands"`
+}
+
+func NewSuggestionsDto(suggestions *repositories.Suggestions) *SuggestionsDto {
+	return &SuggestionsDto{Names: suggestions.Names, Brands: suggestions.Brands}
 }
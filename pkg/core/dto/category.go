@@ -0,0 +1,27 @@
+package dto
+
+import "github.com/micro-eshop/catalog/pkg/core/model"
+
+type CategoryDto struct {
+	ID       int            `json:"id"`
+	Name     string         `json:"name"`
+	ParentID *int           `json:"parentId"`
+	Children []*CategoryDto `json:"children"`
+}
+
+func NewCategoryDto(category *model.Category) *CategoryDto {
+	var parentID *int
+	if category.ParentID != nil {
+		id := int(*category.ParentID)
+		parentID = &id
+	}
+	return &CategoryDto{ID: int(category.ID), Name: category.Name, ParentID: parentID, Children: NewCategoryDtos(category.Children)}
+}
+
+func NewCategoryDtos(categories []*model.Category) []*CategoryDto {
+	result := make([]*CategoryDto, len(categories))
+	for i, category := range categories {
+		result[i] = NewCategoryDto(category)
+	}
+	return result
+}
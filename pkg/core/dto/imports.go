@@ -0,0 +1,57 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+)
+
+type ImportJobDto struct {
+	ID              int64                          `json:"id"`
+	Status          repositories.ImportRunStatus   `json:"status"`
+	Source          string                         `json:"source"`
+	Options         *repositories.ImportJobOptions `json:"options"`
+	Offset          int                            `json:"offset"`
+	Records         int                            `json:"records"`
+	Created         int                            `json:"created"`
+	Restored        int                            `json:"restored"`
+	Updated         int                            `json:"updated"`
+	Unchanged       int                            `json:"unchanged"`
+	Invalid         int                            `json:"invalid"`
+	Failed          int                            `json:"failed"`
+	Archived        int                            `json:"archived"`
+	Error           string                         `json:"error,omitempty"`
+	RowErrors       []repositories.RowError        `json:"rowErrors"`
+	CancelRequested bool                           `json:"cancelRequested"`
+	StartedAt       time.Time                      `json:"startedAt"`
+	UpdatedAt       time.Time                      `json:"updatedAt"`
+	FinishedAt      *time.Time                     `json:"finishedAt"`
+}
+
+func NewImportJobDto(run *repositories.ImportRun) *ImportJobDto {
+	rowErrors := run.RowErrors
+	if rowErrors == nil {
+		rowErrors = []repositories.RowError{}
+	}
+	return &ImportJobDto{
+		ID:              run.ID,
+		Status:          run.Status,
+		Source:          run.SourcePath,
+		Options:         run.Options,
+		Offset:          run.Offset,
+		Records:         run.Records,
+		Created:         run.Created,
+		Restored:        run.Restored,
+		Updated:         run.Updated,
+		Unchanged:       run.Unchanged,
+		Invalid:         run.Invalid,
+		Failed:          run.Failed,
+		Archived:        run.Archived,
+		Error:           run.Error,
+		RowErrors:       rowErrors,
+		CancelRequested: run.CancelRequested,
+		StartedAt:       run.StartedAt,
+		UpdatedAt:       run.UpdatedAt,
+		FinishedAt:      run.FinishedAt,
+	}
+}
@@ -0,0 +1,35 @@
+package dto
+
+import "encoding/json"
+
+// MergePatch applies a JSON Merge Patch (RFC 7396) to the document. Objects are merged recursively,
+// null removes a member and every other value replaces the target value.
+func MergePatch(document, patch []byte) ([]byte, error) {
+	var target, changes interface{}
+	if err := json.Unmarshal(document, &target); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &changes); err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergePatch(target, changes))
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	changes, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	document, ok := target.(map[string]interface{})
+	if !ok {
+		document = make(map[string]interface{})
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(document, key)
+			continue
+		}
+		document[key] = mergePatch(document[key], value)
+	}
+	return document
+}
@@ -0,0 +1,32 @@
+package dto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		document string
+		patch    string
+		expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+	}
+	for _, test := range tests {
+		subject, err := MergePatch([]byte(test.document), []byte(test.patch))
+		assert.Nil(t, err)
+		assert.JSONEq(t, test.expected, string(subject))
+	}
+}
+
+func TestMergePatchWhenPatchIsMalformed(t *testing.T) {
+	_, err := MergePatch([]byte(`{"a":"b"}`), []byte(`{`))
+	assert.NotNil(t, err)
+}
@@ -3,5 +3,17 @@ package error
 import "errors"
 
 var (
-	ErrProductNotFound = errors.New("product not found")
+	ErrProductNotFound         = errors.New("product not found")
+	ErrProductAlreadyExists    = errors.New("product already exists")
+	ErrInvalidProduct          = errors.New("invalid product")
+	ErrCategoryNotFound        = errors.New("category not found")
+	ErrInvalidSearchParams     = errors.New("invalid search params")
+	ErrTooManyProductIds       = errors.New("too many product ids")
+	ErrSyncThresholdExceeded   = errors.New("sync would remove too many products")
+	ErrImportErrorRateExceeded = errors.New("too many import errors")
+	ErrInvalidImportJob        = errors.New("invalid import job")
+	ErrImportJobNotFound       = errors.New("import job not found")
+	ErrImportJobFinished       = errors.New("import job already finished")
+	ErrImportRunLost           = errors.New("import run was taken over by another process")
+	ErrDeltaRejected           = errors.New("delta rejected")
 )
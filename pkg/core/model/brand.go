@@ -0,0 +1,37 @@
+package model
+
+import (
+	"strings"
+)
+
+type BrandId int
+
+type Brand struct {
+	ID      BrandId
+	Slug    string
+	Name    string
+	LogoURL *string
+}
+
+func NewBrand(id BrandId, name string, logoURL *string) *Brand {
+	return &Brand{ID: id, Slug: NewBrandSlug(name), Name: strings.TrimSpace(name), LogoURL: logoURL}
+}
+
+// NewBrandSlug normalizes a brand name, so different spellings of the same brand (".NET", ".net", " .Net ") resolve to one brand.
+// Lowercased ASCII letters and digits are kept, every other run of characters becomes a single dash.
+func NewBrandSlug(name string) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && slug.Len() > 0 {
+				slug.WriteRune('-')
+			}
+			slug.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return slug.String()
+}
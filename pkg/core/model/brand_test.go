@@ -0,0 +1,21 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBrandSlug(t *testing.T) {
+	assert.Equal(t, "net", NewBrandSlug(".NET"))
+	assert.Equal(t, "net", NewBrandSlug(" .net "))
+	assert.Equal(t, "black-white", NewBrandSlug("Black & White"))
+	assert.Equal(t, "", NewBrandSlug("!!!"))
+}
+
+func TestNewBrand(t *testing.T) {
+	subject := NewBrand(BrandId(1), " .NET ", nil)
+	assert.Equal(t, "net", subject.Slug)
+	assert.Equal(t, ".NET", subject.Name)
+	assert.Nil(t, subject.LogoURL)
+}
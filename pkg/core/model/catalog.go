@@ -1,6 +1,9 @@
 package model
 
-import "errors"
+import (
+	"errors"
+	"strings"
+)
 
 type ProductId int
 
@@ -11,6 +14,8 @@ type Product struct {
 	Description    string
 	Price          float64
 	PromotionPrice *float64
+	// Category is the name of the category the product is listed in. Empty when the product is not categorized.
+	Category string
 }
 
 type Products = []Product
@@ -24,7 +29,25 @@ func NewProduct(id ProductId, name, brand, description string, price float64) *P
 }
 
 func ValidateProduct(product *Product) error {
-	return ValidateProductId(product.ID)
+	err := ValidateProductId(product.ID)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(product.Name) == "" {
+		return errors.New("Name can't be empty")
+	}
+	if product.Price < 0 {
+		return errors.New("Price can't be negative")
+	}
+	if product.PromotionPrice != nil {
+		if *product.PromotionPrice <= 0 {
+			return errors.New("Promotion price must be positive")
+		}
+		if *product.PromotionPrice >= product.Price {
+			return errors.New("Promotion price must be lower than price")
+		}
+	}
+	return nil
 }
 
 func ValidateProductId(id ProductId) error {
@@ -30,3 +30,33 @@ func TestProductIdsValidationWhenIsInValid(t *testing.T) {
 	subject := ValidateProductIds(ids)
 	assert.EqualError(t, subject, "ProductId must be greater than 0")
 }
+
+func TestProductValidationWhenIsValid(t *testing.T) {
+	subject := ValidateProduct(NewProduct(ProductId(1), "name", "brand", "description", 1.0))
+	assert.Nil(t, subject)
+}
+
+func TestProductValidationWhenNameIsEmpty(t *testing.T) {
+	subject := ValidateProduct(NewProduct(ProductId(1), " ", "brand", "description", 1.0))
+	assert.EqualError(t, subject, "Name can't be empty")
+}
+
+func TestProductValidationWhenPriceIsNegative(t *testing.T) {
+	subject := ValidateProduct(NewProduct(ProductId(1), "name", "brand", "description", -1.0))
+	assert.EqualError(t, subject, "Price can't be negative")
+}
+
+func TestProductValidationWhenPromotionPriceIsNotPositive(t *testing.T) {
+	subject := ValidateProduct(NewPromotionalProduct(ProductId(1), "name", "brand", "description", 1.0, 0))
+	assert.EqualError(t, subject, "Promotion price must be positive")
+}
+
+func TestProductValidationWhenPromotionPriceIsNotLower(t *testing.T) {
+	subject := ValidateProduct(NewPromotionalProduct(ProductId(1), "name", "brand", "description", 1.0, 1.0))
+	assert.EqualError(t, subject, "Promotion price must be lower than price")
+}
+
+func TestProductValidationWhenPromotionPriceIsValid(t *testing.T) {
+	subject := ValidateProduct(NewPromotionalProduct(ProductId(1), "name", "brand", "description", 1.0, 0.5))
+	assert.Nil(t, subject)
+}
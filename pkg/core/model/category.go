@@ -0,0 +1,55 @@
+package model
+
+import (
+	"errors"
+	"sort"
+)
+
+type CategoryId int
+
+type Category struct {
+	ID       CategoryId
+	Name     string
+	ParentID *CategoryId
+	Children []*Category
+}
+
+func NewCategory(id CategoryId, name string, parentID *CategoryId) *Category {
+	return &Category{ID: id, Name: name, ParentID: parentID, Children: make([]*Category, 0)}
+}
+
+func ValidateCategoryId(id CategoryId) error {
+	if id > 0 {
+		return nil
+	}
+	return errors.New("CategoryId must be greater than 0")
+}
+
+// BuildCategoryTree links categories to their parents and returns the roots. Categories whose parent is missing are treated as roots.
+// Every level is sorted by name.
+func BuildCategoryTree(categories []*Category) []*Category {
+	byId := make(map[CategoryId]*Category, len(categories))
+	for _, category := range categories {
+		category.Children = make([]*Category, 0)
+		byId[category.ID] = category
+	}
+	roots := make([]*Category, 0)
+	for _, category := range categories {
+		if category.ParentID != nil {
+			if parent, ok := byId[*category.ParentID]; ok && parent != category {
+				parent.Children = append(parent.Children, category)
+				continue
+			}
+		}
+		roots = append(roots, category)
+	}
+	sortCategories(roots)
+	return roots
+}
+
+func sortCategories(categories []*Category) {
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Name < categories[j].Name })
+	for _, category := range categories {
+		sortCategories(category.Children)
+	}
+}
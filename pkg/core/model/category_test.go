@@ -0,0 +1,38 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCategoryIdValidationWhenIsInvalid(t *testing.T) {
+	subject := ValidateCategoryId(CategoryId(0))
+	assert.EqualError(t, subject, "CategoryId must be greater than 0")
+}
+
+func TestBuildCategoryTree(t *testing.T) {
+	apparel := CategoryId(1)
+	tshirts := CategoryId(2)
+	categories := []*Category{
+		NewCategory(tshirts, "T-Shirt", &apparel),
+		NewCategory(CategoryId(3), "Mug", nil),
+		NewCategory(apparel, "Apparel", nil),
+		NewCategory(CategoryId(4), "Hoodie", &apparel),
+	}
+	subject := BuildCategoryTree(categories)
+	assert.Len(t, subject, 2)
+	assert.Equal(t, "Apparel", subject[0].Name)
+	assert.Equal(t, "Mug", subject[1].Name)
+	assert.Len(t, subject[0].Children, 2)
+	assert.Equal(t, "Hoodie", subject[0].Children[0].Name)
+	assert.Equal(t, "T-Shirt", subject[0].Children[1].Name)
+	assert.Empty(t, subject[1].Children)
+}
+
+func TestBuildCategoryTreeWhenParentIsMissing(t *testing.T) {
+	missing := CategoryId(10)
+	subject := BuildCategoryTree([]*Category{NewCategory(CategoryId(1), "Mug", &missing)})
+	assert.Len(t, subject, 1)
+	assert.Equal(t, "Mug", subject[0].Name)
+}
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// FieldChange is one field that differs between two versions of a product. Promotion prices are *float64, nil when not in promotion.
+type FieldChange struct {
+	Field string
+	Old   interface{}
+	New   interface{}
+}
+
+// Changes lists the fields that differ between two versions of a product, using the names of the public product contract.
+func Changes(old, new *Product) []FieldChange {
+	changes := make([]FieldChange, 0)
+	if old.Name != new.Name {
+		changes = append(changes, FieldChange{Field: "name", Old: old.Name, New: new.Name})
+	}
+	if old.Brand != new.Brand {
+		changes = append(changes, FieldChange{Field: "brand", Old: old.Brand, New: new.Brand})
+	}
+	if old.Description != new.Description {
+		changes = append(changes, FieldChange{Field: "description", Old: old.Description, New: new.Description})
+	}
+	if old.Price != new.Price {
+		changes = append(changes, FieldChange{Field: "price", Old: old.Price, New: new.Price})
+	}
+	if !equalPrices(old.PromotionPrice, new.PromotionPrice) {
+		changes = append(changes, FieldChange{Field: "promotionPrice", Old: old.PromotionPrice, New: new.PromotionPrice})
+	}
+	if old.Category != new.Category {
+		changes = append(changes, FieldChange{Field: "category", Old: old.Category, New: new.Category})
+	}
+	return changes
+}
+
+// ChangedFields lists the names of the fields that differ between two versions of a product.
+func ChangedFields(old, new *Product) []string {
+	changes := Changes(old, new)
+	fields := make([]string, len(changes))
+	for i, change := range changes {
+		fields[i] = change.Field
+	}
+	return fields
+}
+
+// PriceChanged reports whether the regular or the promotion price differs between two versions of a product.
+func PriceChanged(old, new *Product) bool {
+	return old.Price != new.Price || !equalPrices(old.PromotionPrice, new.PromotionPrice)
+}
+
+func equalPrices(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// ContentHash fingerprints the imported fields of a product, so a re-import can skip products that did not change.
+func ContentHash(product *Product) string {
+	promotionPrice := ""
+	if product.PromotionPrice != nil {
+		promotionPrice = strconv.FormatFloat(*product.PromotionPrice, 'g', -1, 64)
+	}
+	hash := sha256.New()
+	for _, field := range []string{
+		product.Name,
+		product.Brand,
+		product.Description,
+		strconv.FormatFloat(product.Price, 'g', -1, 64),
+		promotionPrice,
+		product.Category,
+	} {
+		hash.Write([]byte(field))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
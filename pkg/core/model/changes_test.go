@@ -0,0 +1,40 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangedFieldsWhenNothingChanged(t *testing.T) {
+	old := NewPromotionalProduct(ProductId(1), "name", "brand", "description", 2.0, 1.0)
+	new := NewPromotionalProduct(ProductId(1), "name", "brand", "description", 2.0, 1.0)
+	assert.Empty(t, ChangedFields(old, new))
+	assert.False(t, PriceChanged(old, new))
+}
+
+func TestChangedFieldsWhenPromotionEnds(t *testing.T) {
+	old := NewPromotionalProduct(ProductId(1), "name", "brand", "description", 2.0, 1.0)
+	new := NewProduct(ProductId(1), "new name", "brand", "description", 2.0)
+	assert.Equal(t, []string{"name", "promotionPrice"}, ChangedFields(old, new))
+	assert.True(t, PriceChanged(old, new))
+}
+
+func TestContentHash(t *testing.T) {
+	product := NewPromotionalProduct(ProductId(1), "name", "brand", "description", 2.0, 1.0)
+	same := NewPromotionalProduct(ProductId(1), "name", "brand", "description", 2.0, 1.0)
+	assert.Equal(t, ContentHash(product), ContentHash(same))
+	assert.NotEqual(t, ContentHash(product), ContentHash(NewProduct(ProductId(1), "name", "brand", "description", 2.0)))
+	assert.NotEqual(t, ContentHash(NewProduct(ProductId(1), "ab", "c", "", 1.0)), ContentHash(NewProduct(ProductId(1), "a", "bc", "", 1.0)))
+}
+
+func TestChanges(t *testing.T) {
+	old := NewPromotionalProduct(ProductId(1), "name", "brand", "description", 2.0, 1.0)
+	new := NewProduct(ProductId(1), "name", "brand", "description", 3.0)
+	promotionPrice := 1.0
+	expected := []FieldChange{
+		{Field: "price", Old: 2.0, New: 3.0},
+		{Field: "promotionPrice", Old: &promotionPrice, New: (*float64)(nil)},
+	}
+	assert.Equal(t, expected, Changes(old, new))
+}
@@ -0,0 +1,12 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/micro-eshop/catalog/pkg/core/model"
+)
+
+type BrandReader interface {
+	GetBrands(ctx context.Context) ([]*model.Brand, error)
+	GetBrandBySlug(ctx context.Context, slug string) (*model.Brand, error)
+}
@@ -2,26 +2,267 @@ package repositories
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
 
+	coreerr "github.com/micro-eshop/catalog/pkg/core/error"
 	"github.com/micro-eshop/catalog/pkg/core/model"
 )
 
+const (
+	DefaultPageSize     = 20
+	MaxPageSize         = 100
+	DefaultSuggestLimit = 5
+	MaxSuggestLimit     = 20
+)
+
+type SortField string
+
+const (
+	SortById    SortField = "id"
+	SortByName  SortField = "name"
+	SortByBrand SortField = "brand"
+	SortByPrice SortField = "price"
+	// SortByRelevance orders by full-text rank and is only available together with a Query.
+	SortByRelevance SortField = "relevance"
+)
+
+type SortDirection string
+
+const (
+	SortAsc  SortDirection = "asc"
+	SortDesc SortDirection = "desc"
+)
+
 type ProductSearchParams struct {
-	Name        string
-	Brand       string
+	// Query is a free-text query matched against name, brand and description. Every word is matched as a prefix.
+	Query string
+	Name  string
+	Brand string
+	// Brands matches any of the given brands exactly, so several brand facets can be selected at once.
+	Brands      []string
 	PriceFrom   float64
 	PriceTo     float64
 	InPromotion bool
+	// CategoryID limits results to products of the category and all of its descendants.
+	CategoryID    model.CategoryId
+	PageSize      int
+	Cursor        string
+	SortBy        SortField
+	SortDirection SortDirection
+}
+
+// PriceBucketBounds splits products into the price buckets [0, 10), [10, 25), [25, 50), [50, 100) and [100, ...).
+var PriceBucketBounds = []float64{10, 25, 50, 100}
+
+type FacetCount struct {
+	Value string
+	Count int
+}
+
+type PriceBucketCount struct {
+	From  float64
+	To    *float64
+	Count int
+}
+
+type PromotionFacet struct {
+	InPromotion    int
+	NotInPromotion int
+}
+
+// ProductFacets are computed within the scope of the search filters. Every facet ignores its own filter,
+// so the counts of the other values of a facet stay visible once one of them is selected.
+type ProductFacets struct {
+	Brands       []FacetCount
+	PriceBuckets []PriceBucketCount
+	Promotion    PromotionFacet
+}
+
+// NewPriceBucketCounts returns an empty bucket for every range defined by bounds.
+func NewPriceBucketCounts(bounds []float64) []PriceBucketCount {
+	buckets := make([]PriceBucketCount, len(bounds)+1)
+	for i := range buckets {
+		if i > 0 {
+			buckets[i].From = bounds[i-1]
+		}
+		if i < len(bounds) {
+			to := bounds[i]
+			buckets[i].To = &to
+		}
+	}
+	return buckets
+}
+
+type ProductsPage struct {
+	Products   []*model.Product
+	Total      int
+	NextCursor string
+}
+
+// ProductCursor points at the last product of a page. The next page starts right after it in the (sort key, id) order.
+type ProductCursor struct {
+	SortBy        SortField       `json:"s"`
+	SortDirection SortDirection   `json:"d"`
+	SortValue     interface{}     `json:"v,omitempty"`
+	ID            model.ProductId `json:"id"`
+}
+
+func EncodeProductCursor(cursor ProductCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeProductCursor(params ProductSearchParams) (*ProductCursor, error) {
+	if params.Cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(params.Cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", coreerr.ErrInvalidSearchParams)
+	}
+	var cursor ProductCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", coreerr.ErrInvalidSearchParams)
+	}
+	if cursor.SortBy != params.SortBy || cursor.SortDirection != params.SortDirection {
+		return nil, fmt.Errorf("%w: cursor does not match sort order", coreerr.ErrInvalidSearchParams)
+	}
+	switch cursor.SortBy {
+	case SortByName, SortByBrand:
+		if _, ok := cursor.SortValue.(string); !ok {
+			return nil, fmt.Errorf("%w: malformed cursor", coreerr.ErrInvalidSearchParams)
+		}
+	case SortByPrice, SortByRelevance:
+		if _, ok := cursor.SortValue.(float64); !ok {
+			return nil, fmt.Errorf("%w: malformed cursor", coreerr.ErrInvalidSearchParams)
+		}
+	}
+	return &cursor, nil
+}
+
+// NormalizeProductSearchParams fills in the default sort order and clamps the page size to MaxPageSize.
+func NormalizeProductSearchParams(params ProductSearchParams) ProductSearchParams {
+	if params.PageSize <= 0 {
+		params.PageSize = DefaultPageSize
+	}
+	if params.PageSize > MaxPageSize {
+		params.PageSize = MaxPageSize
+	}
+	if params.SortBy == "" && params.Query != "" {
+		params.SortBy = SortByRelevance
+	}
+	if params.SortBy == "" {
+		params.SortBy = SortById
+	}
+	if params.SortDirection == "" && params.SortBy == SortByRelevance {
+		params.SortDirection = SortDesc
+	}
+	if params.SortDirection == "" {
+		params.SortDirection = SortAsc
+	}
+	return params
+}
+
+func ValidateProductSearchParams(params ProductSearchParams) error {
+	if params.PriceFrom < 0 || params.PriceTo < 0 {
+		return fmt.Errorf("%w: price range can't be negative", coreerr.ErrInvalidSearchParams)
+	}
+	if params.PriceTo != 0 && params.PriceFrom > params.PriceTo {
+		return fmt.Errorf("%w: priceFrom must be less than or equal to priceTo", coreerr.ErrInvalidSearchParams)
+	}
+	if params.PageSize < 0 {
+		return fmt.Errorf("%w: pageSize can't be negative", coreerr.ErrInvalidSearchParams)
+	}
+	switch params.SortBy {
+	case "", SortById, SortByName, SortByBrand, SortByPrice:
+	case SortByRelevance:
+		if params.Query == "" {
+			return fmt.Errorf("%w: sorting by relevance requires a query", coreerr.ErrInvalidSearchParams)
+		}
+	default:
+		return fmt.Errorf("%w: unknown sort field %q", coreerr.ErrInvalidSearchParams, params.SortBy)
+	}
+	switch params.SortDirection {
+	case "", SortAsc, SortDesc:
+	default:
+		return fmt.Errorf("%w: unknown sort direction %q", coreerr.ErrInvalidSearchParams, params.SortDirection)
+	}
+	_, err := DecodeProductCursor(params)
+	return err
+}
+
+type Suggestions struct {
+	Names  []string
+	Brands []string
+}
+
+func ValidateSuggestQuery(prefix string, limit int) error {
+	if strings.TrimSpace(prefix) == "" {
+		return fmt.Errorf("%w: query can't be empty", coreerr.ErrInvalidSearchParams)
+	}
+	if limit < 0 {
+		return fmt.Errorf("%w: limit can't be negative", coreerr.ErrInvalidSearchParams)
+	}
+	return nil
+}
+
+// NormalizeSuggestLimit returns DefaultSuggestLimit when limit is not set and clamps it to MaxSuggestLimit.
+func NormalizeSuggestLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultSuggestLimit
+	}
+	if limit > MaxSuggestLimit {
+		return MaxSuggestLimit
+	}
+	return limit
 }
 
 type CatalogReader interface {
 	GetProductById(ctx context.Context, id model.ProductId) (*model.Product, error)
 	GetProductByIds(ctx context.Context, ids ...model.ProductId) ([]*model.Product, error)
-	Search(ctx context.Context, params ProductSearchParams) ([]*model.Product, error)
+	Search(ctx context.Context, params ProductSearchParams) (*ProductsPage, error)
+	Facets(ctx context.Context, params ProductSearchParams) (*ProductFacets, error)
+	Suggest(ctx context.Context, prefix string, limit int) (*Suggestions, error)
+	// GetProductIds lists the ids of all products that are not archived.
+	GetProductIds(ctx context.Context) ([]model.ProductId, error)
+	// GetStoredProducts returns the products with the given ids the way an import compares them, archived ones included.
+	GetStoredProducts(ctx context.Context, ids ...model.ProductId) (map[model.ProductId]*StoredProduct, error)
+}
+
+// StoredProduct is a product with the content hash UpsertBatch compares a new version against.
+type StoredProduct struct {
+	Product *model.Product
+	// ContentHash is empty for products stored before content hashes were recorded, they are always rewritten.
+	ContentHash string
+	Archived    bool
 }
 
+type UpsertStatus string
+
+const (
+	UpsertCreated   UpsertStatus = "created"
+	UpsertRestored  UpsertStatus = "restored"
+	UpsertUpdated   UpsertStatus = "updated"
+	UpsertUnchanged UpsertStatus = "unchanged"
+)
+
+// UpsertResult tells for every product id of a batch whether the product was created, restored from the archive,
+// updated or left as it was because its content hash did not change.
+type UpsertResult map[model.ProductId]UpsertStatus
+
+// CatalogWriter returns ErrProductAlreadyExists when inserting a product with a taken id
+// and ErrProductNotFound when updating or deleting a product that does not exist.
+// UpsertBatch creates or replaces all products of the batch at once; when an id repeats, the last product wins.
+// Archive hides products from every read without deleting them, upserting an archived product restores it.
 type CatalogWriter interface {
 	Insert(ctx context.Context, product *model.Product) error
+	Update(ctx context.Context, product *model.Product) error
+	Delete(ctx context.Context, id model.ProductId) error
+	UpsertBatch(ctx context.Context, products []*model.Product) (UpsertResult, error)
+	Archive(ctx context.Context, ids ...model.ProductId) error
 }
 
 type CatalogRepository interface {
@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"testing"
+
+	coreerr "github.com/micro-eshop/catalog/pkg/core/error"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductSearchParamsValidationWhenIsValid(t *testing.T) {
+	subject := ValidateProductSearchParams(ProductSearchParams{PriceFrom: 1, PriceTo: 10})
+	assert.Nil(t, subject)
+}
+
+func TestProductSearchParamsValidationWhenPriceToIsNotSet(t *testing.T) {
+	subject := ValidateProductSearchParams(ProductSearchParams{PriceFrom: 10})
+	assert.Nil(t, subject)
+}
+
+func TestProductSearchParamsValidationWhenPriceRangeIsInverted(t *testing.T) {
+	subject := ValidateProductSearchParams(ProductSearchParams{PriceFrom: 10, PriceTo: 1})
+	assert.ErrorIs(t, subject, coreerr.ErrInvalidSearchParams)
+}
+
+func TestProductSearchParamsValidationWhenPriceIsNegative(t *testing.T) {
+	subject := ValidateProductSearchParams(ProductSearchParams{PriceFrom: -1})
+	assert.ErrorIs(t, subject, coreerr.ErrInvalidSearchParams)
+}
+
+func TestProductSearchParamsNormalizationWhenPageSizeIsTooBig(t *testing.T) {
+	subject := NormalizeProductSearchParams(ProductSearchParams{PageSize: MaxPageSize + 1})
+	assert.Equal(t, MaxPageSize, subject.PageSize)
+	assert.Equal(t, SortById, subject.SortBy)
+	assert.Equal(t, SortAsc, subject.SortDirection)
+}
+
+func TestProductSearchParamsNormalizationWhenPageSizeIsNotSet(t *testing.T) {
+	subject := NormalizeProductSearchParams(ProductSearchParams{})
+	assert.Equal(t, DefaultPageSize, subject.PageSize)
+}
+
+func TestProductCursorRoundTrip(t *testing.T) {
+	params := ProductSearchParams{SortBy: SortByPrice, SortDirection: SortDesc}
+	params.Cursor = EncodeProductCursor(ProductCursor{SortBy: SortByPrice, SortDirection: SortDesc, SortValue: 12.5, ID: 3})
+	subject, err := DecodeProductCursor(params)
+	assert.Nil(t, err)
+	assert.Equal(t, 12.5, subject.SortValue)
+	assert.Equal(t, 3, int(subject.ID))
+}
+
+func TestProductCursorWhenSortOrderDoesNotMatch(t *testing.T) {
+	params := ProductSearchParams{SortBy: SortByName, SortDirection: SortAsc}
+	params.Cursor = EncodeProductCursor(ProductCursor{SortBy: SortByPrice, SortDirection: SortAsc, SortValue: 12.5, ID: 3})
+	_, err := DecodeProductCursor(params)
+	assert.ErrorIs(t, err, coreerr.ErrInvalidSearchParams)
+}
+
+func TestProductCursorWhenIsMalformed(t *testing.T) {
+	_, err := DecodeProductCursor(ProductSearchParams{Cursor: "not a cursor"})
+	assert.ErrorIs(t, err, coreerr.ErrInvalidSearchParams)
+}
+
+func TestProductSearchParamsNormalizationWhenQueryIsSet(t *testing.T) {
+	subject := NormalizeProductSearchParams(ProductSearchParams{Query: "hood"})
+	assert.Equal(t, SortByRelevance, subject.SortBy)
+	assert.Equal(t, SortDesc, subject.SortDirection)
+}
+
+func TestProductSearchParamsValidationWhenSortedByRelevanceWithoutQuery(t *testing.T) {
+	subject := ValidateProductSearchParams(ProductSearchParams{SortBy: SortByRelevance})
+	assert.ErrorIs(t, subject, coreerr.ErrInvalidSearchParams)
+}
+
+func TestNewPriceBucketCounts(t *testing.T) {
+	subject := NewPriceBucketCounts([]float64{10, 25})
+	assert.Len(t, subject, 3)
+	assert.Equal(t, 0.0, subject[0].From)
+	assert.Equal(t, 10.0, *subject[0].To)
+	assert.Equal(t, 10.0, subject[1].From)
+	assert.Equal(t, 25.0, *subject[1].To)
+	assert.Equal(t, 25.0, subject[2].From)
+	assert.Nil(t, subject[2].To)
+}
+
+func TestSuggestQueryValidationWhenQueryIsBlank(t *testing.T) {
+	subject := ValidateSuggestQuery("  ", 0)
+	assert.ErrorIs(t, subject, coreerr.ErrInvalidSearchParams)
+}
+
+func TestNormalizeSuggestLimit(t *testing.T) {
+	assert.Equal(t, DefaultSuggestLimit, NormalizeSuggestLimit(0))
+	assert.Equal(t, 3, NormalizeSuggestLimit(3))
+	assert.Equal(t, MaxSuggestLimit, NormalizeSuggestLimit(MaxSuggestLimit+1))
+}
@@ -0,0 +1,12 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/micro-eshop/catalog/pkg/core/model"
+)
+
+type CategoryReader interface {
+	GetCategories(ctx context.Context) ([]*model.Category, error)
+	GetCategoryById(ctx context.Context, id model.CategoryId) (*model.Category, error)
+}
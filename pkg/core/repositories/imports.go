@@ -0,0 +1,102 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/micro-eshop/catalog/pkg/core/model"
+)
+
+type ImportRunStatus string
+
+const (
+	ImportRunQueued    ImportRunStatus = "queued"
+	ImportRunRunning   ImportRunStatus = "running"
+	ImportRunCompleted ImportRunStatus = "completed"
+	ImportRunFailed    ImportRunStatus = "failed"
+	ImportRunCancelled ImportRunStatus = "cancelled"
+)
+
+// MaxImportRunRowErrors limits how many rejected rows are kept with a run, the full list goes to the import report.
+const MaxImportRunRowErrors = 100
+
+// RowError describes why a row of the import source was not imported. Column and Value are set for rows that could not be parsed.
+type RowError struct {
+	Line      int             `json:"line"`
+	ProductID model.ProductId `json:"productId,omitempty"`
+	Column    string          `json:"column,omitempty"`
+	Value     string          `json:"value,omitempty"`
+	Reason    string          `json:"reason"`
+}
+
+// ImportJobOptions are the settings of an import started through the api, kept so the job can be resumed.
+type ImportJobOptions struct {
+	Format                string  `json:"format,omitempty"`
+	Columns               string  `json:"columns,omitempty"`
+	Sync                  bool    `json:"sync"`
+	Delta                 bool    `json:"delta,omitempty"`
+	SyncMaxRemovedPercent float64 `json:"syncMaxRemovedPercent"`
+	MaxErrorRate          float64 `json:"maxErrorRate"`
+	// Upload marks a file uploaded for the job, it is removed once the job finished.
+	Upload bool `json:"upload,omitempty"`
+}
+
+// ImportRun records the progress of an import of one source file.
+type ImportRun struct {
+	ID         int64
+	SourcePath string
+	Checksum   string
+	Status     ImportRunStatus
+	// Offset is the line of the last record committed in source order, a resumed run skips the records up to it.
+	Offset int
+	// The counts cover the committed records of every execution of the run.
+	Records   int
+	Created   int
+	Restored  int
+	Updated   int
+	Unchanged int
+	Invalid   int
+	Failed    int
+	Archived  int
+	Error     string
+	RowErrors []RowError
+	// Options are only set for runs started as an import job.
+	Options *ImportJobOptions
+	// Owner identifies the process running the run, the process that claims a run becomes its owner.
+	Owner           string
+	CancelRequested bool
+	StartedAt       time.Time
+	UpdatedAt       time.Time
+	FinishedAt      *time.Time
+}
+
+// Finished tells whether the run reached a final status, a finished run is never executed again.
+func (run *ImportRun) Finished() bool {
+	return run.Status != ImportRunQueued && run.Status != ImportRunRunning
+}
+
+type ImportRunRepository interface {
+	// CreateImportRun stores a new run and sets its id and start time.
+	CreateImportRun(ctx context.Context, run *ImportRun) error
+	// SaveImportRun stores the status, offset, counts and errors of a run. It fails with ErrImportRunLost when
+	// the run is owned by another process than run.Owner.
+	SaveImportRun(ctx context.Context, run *ImportRun) error
+	// GetImportRun returns nil when there is no run with the id.
+	GetImportRun(ctx context.Context, id int64) (*ImportRun, error)
+	// GetLastImportRun returns the most recent run of the source, nil when it was never imported.
+	GetLastImportRun(ctx context.Context, sourcePath string) (*ImportRun, error)
+	// GetImportRuns returns the most recent runs first.
+	GetImportRuns(ctx context.Context, limit int) ([]*ImportRun, error)
+	// ClaimImportRun takes over an unfinished run to resume it. It returns false when the run is still heartbeated
+	// since staleBefore by the process running it, or when another caller claimed it first. A claimed run is owned by owner.
+	ClaimImportRun(ctx context.Context, id int64, owner string, staleBefore time.Time) (bool, error)
+	// ClaimStaleImportJobs hands owner the unfinished jobs nobody heartbeated since staleBefore, their process is gone.
+	// A job is claimed by one caller only and never by the owner it already has.
+	ClaimStaleImportJobs(ctx context.Context, owner string, staleBefore time.Time) ([]*ImportRun, error)
+	// HeartbeatImportRuns marks the runs owner still owns as alive and tells for each of them whether it should be
+	// cancelled. The runs missing from the result were taken over by another process.
+	HeartbeatImportRuns(ctx context.Context, owner string, ids ...int64) (map[int64]bool, error)
+	// RequestImportRunCancel asks the process running the job to cancel it, a queued job is cancelled right away.
+	// It returns false when the run already finished.
+	RequestImportRunCancel(ctx context.Context, id int64) (bool, error)
+}
@@ -0,0 +1,30 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/micro-eshop/catalog/pkg/core/model"
+)
+
+type OutboxMessage struct {
+	ID          int64
+	AggregateID model.ProductId
+	Type        string
+	Payload     []byte
+	Attempts    int
+}
+
+type OutboxRepository interface {
+	// TryLock takes a lock held until the end of the current transaction. Only one relay claims messages at a time,
+	// which keeps the events of every product in order.
+	TryLock(ctx context.Context) (bool, error)
+	// GetPending returns due messages ordered by id. Messages queued behind a message of the same product that waits for a retry,
+	// or that is leased by a relay, are skipped.
+	GetPending(ctx context.Context, limit int) ([]*OutboxMessage, error)
+	// Lease makes the messages due again at until, without counting an attempt. A relay leases the messages it publishes,
+	// and gives back the ones it skipped with until set to now.
+	Lease(ctx context.Context, until time.Time, ids ...int64) error
+	MarkDelivered(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, reason string, nextAttemptAt time.Time) error
+}
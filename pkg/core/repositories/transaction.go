@@ -0,0 +1,9 @@
+package repositories
+
+import "context"
+
+// Transactor runs fn in a transaction carried by the context passed to it. Repositories called with that context
+// take part in the transaction. Nested calls join the outer transaction.
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	coreerr "github.com/micro-eshop/catalog/pkg/core/error"
+	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+)
+
+type CatalogAdminService interface {
+	GetProductById(ctx context.Context, id model.ProductId) (*model.Product, error)
+	CreateProduct(ctx context.Context, product *model.Product) error
+	UpdateProduct(ctx context.Context, product *model.Product) error
+	DeleteProduct(ctx context.Context, id model.ProductId) error
+}
+
+type catalogAdminService struct {
+	repo      repositories.CatalogRepository
+	tx        repositories.Transactor
+	publisher ProductEventsPublisher
+}
+
+// NewCatalogAdminService writes every change and its events in one transaction, so publisher should be the outbox.
+func NewCatalogAdminService(repo repositories.CatalogRepository, tx repositories.Transactor, publisher ProductEventsPublisher) *catalogAdminService {
+	return &catalogAdminService{
+		repo:      repo,
+		tx:        tx,
+		publisher: publisher,
+	}
+}
+
+func validateProduct(product *model.Product) error {
+	if err := model.ValidateProduct(product); err != nil {
+		return fmt.Errorf("%w: %s", coreerr.ErrInvalidProduct, err)
+	}
+	return nil
+}
+
+func (s *catalogAdminService) GetProductById(ctx context.Context, id model.ProductId) (*model.Product, error) {
+	if err := model.ValidateProductId(id); err != nil {
+		return nil, fmt.Errorf("%w: %s", coreerr.ErrInvalidProduct, err)
+	}
+	return s.repo.GetProductById(ctx, id)
+}
+
+func (s *catalogAdminService) CreateProduct(ctx context.Context, product *model.Product) error {
+	if err := validateProduct(product); err != nil {
+		return err
+	}
+	return s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.Insert(ctx, product); err != nil {
+			return err
+		}
+		return s.publisher.PublishProductCreated(ctx, NewProductCreated(product))
+	})
+}
+
+func (s *catalogAdminService) UpdateProduct(ctx context.Context, product *model.Product) error {
+	if err := validateProduct(product); err != nil {
+		return err
+	}
+	return s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		current, err := s.repo.GetProductById(ctx, product.ID)
+		if err != nil {
+			return err
+		}
+		if current == nil {
+			return coreerr.ErrProductNotFound
+		}
+		if err := s.repo.Update(ctx, product); err != nil {
+			return err
+		}
+		if len(model.ChangedFields(current, product)) > 0 {
+			if err := s.publisher.PublishProductUpdated(ctx, NewProductUpdated(current, product)); err != nil {
+				return err
+			}
+		}
+		if model.PriceChanged(current, product) {
+			return s.publisher.PublishProductPriceChanged(ctx, NewProductPriceChanged(current, product))
+		}
+		return nil
+	})
+}
+
+func (s *catalogAdminService) DeleteProduct(ctx context.Context, id model.ProductId) error {
+	if err := model.ValidateProductId(id); err != nil {
+		return fmt.Errorf("%w: %s", coreerr.ErrInvalidProduct, err)
+	}
+	return s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.Delete(ctx, id); err != nil {
+			return err
+		}
+		return s.publisher.PublishProductDeleted(ctx, NewProductDeleted(id))
+	})
+}
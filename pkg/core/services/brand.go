@@ -0,0 +1,34 @@
+package services
+
+import (
+	"context"
+
+	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+)
+
+type BrandService interface {
+	GetBrands(ctx context.Context) ([]*model.Brand, error)
+	GetBrandBySlug(ctx context.Context, slug string) (*model.Brand, error)
+}
+
+type brandService struct {
+	repo repositories.BrandReader
+}
+
+func NewBrandService(repo repositories.BrandReader) *brandService {
+	return &brandService{
+		repo: repo,
+	}
+}
+
+func (s *brandService) GetBrands(ctx context.Context) ([]*model.Brand, error) {
+	return s.repo.GetBrands(ctx)
+}
+
+func (s *brandService) GetBrandBySlug(ctx context.Context, slug string) (*model.Brand, error) {
+	if model.NewBrandSlug(slug) == "" {
+		return nil, nil
+	}
+	return s.repo.GetBrandBySlug(ctx, slug)
+}
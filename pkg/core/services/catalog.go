@@ -2,16 +2,21 @@ package services
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"sync"
 
+	coreerr "github.com/micro-eshop/catalog/pkg/core/error"
 	"github.com/micro-eshop/catalog/pkg/core/model"
 	"github.com/micro-eshop/catalog/pkg/core/repositories"
-	log "github.com/sirupsen/logrus"
 )
 
 type CatalogService interface {
 	GetProductById(ctx context.Context, id model.ProductId) (*model.Product, error)
 	GetProductByIds(ctx context.Context, ids []model.ProductId) ([]*model.Product, error)
-	Search(ctx context.Context, params repositories.ProductSearchParams) ([]*model.Product, error)
+	Search(ctx context.Context, params repositories.ProductSearchParams) (*repositories.ProductsPage, error)
+	Facets(ctx context.Context, params repositories.ProductSearchParams) (*repositories.ProductFacets, error)
+	Suggest(ctx context.Context, prefix string, limit int) (*repositories.Suggestions, error)
 }
 
 type catalogService struct {
@@ -37,6 +42,10 @@ func (s *catalogService) GetProductByIds(ctx context.Context, ids []model.Produc
 		return make([]*model.Product, 0), nil
 	}
 
+	if len(ids) > repositories.MaxPageSize {
+		return nil, fmt.Errorf("%w: at most %d ids can be requested at once", coreerr.ErrTooManyProductIds, repositories.MaxPageSize)
+	}
+
 	err := model.ValidateProductIds(ids)
 	if err != nil {
 		return nil, err
@@ -44,68 +53,558 @@ func (s *catalogService) GetProductByIds(ctx context.Context, ids []model.Produc
 	return s.repo.GetProductByIds(ctx, ids...)
 }
 
-func (s *catalogService) Search(ctx context.Context, params repositories.ProductSearchParams) ([]*model.Product, error) {
+func (s *catalogService) Search(ctx context.Context, params repositories.ProductSearchParams) (*repositories.ProductsPage, error) {
+	params = repositories.NormalizeProductSearchParams(params)
+	err := repositories.ValidateProductSearchParams(params)
+	if err != nil {
+		return nil, err
+	}
 	return s.repo.Search(ctx, params)
 }
 
+func (s *catalogService) Facets(ctx context.Context, params repositories.ProductSearchParams) (*repositories.ProductFacets, error) {
+	params = repositories.NormalizeProductSearchParams(params)
+	err := repositories.ValidateProductSearchParams(params)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.Facets(ctx, params)
+}
+
+func (s *catalogService) Suggest(ctx context.Context, prefix string, limit int) (*repositories.Suggestions, error) {
+	err := repositories.ValidateSuggestQuery(prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.Suggest(ctx, strings.TrimSpace(prefix), repositories.NormalizeSuggestLimit(limit))
+}
+
+const (
+	DefaultImportBatchSize = 500
+	DefaultImportWorkers   = 1
+)
+
+// ImportPipelineOptions configures how records are batched and how many batches are processed at once.
+type ImportPipelineOptions struct {
+	// BatchSize is the number of records stored in one transaction, DefaultImportBatchSize when zero.
+	BatchSize int
+	// Workers is the number of batches processed concurrently, DefaultImportWorkers when zero.
+	Workers int
+	// Unordered yields batch results as soon as they are done instead of in source order. Either way, batches sharing
+	// a product are never processed at the same time, so a repeated product ends up stored in its last version.
+	Unordered bool
+}
+
+// RowError describes why a row of the import source was not imported, it is stored with the import run.
+type RowError = repositories.RowError
+
+// SourceRecord is one product read from an import source. Errors lists the fields that could not be parsed,
+// such a record is reported instead of imported.
+type SourceRecord struct {
+	Line    int
+	Product *model.Product
+	Errors  []RowError
+	// Op is only read by a delta import.
+	Op DeltaOp
+}
+
+// ProductDiff describes what an import would do to one product.
+type ProductDiff struct {
+	Line    int
+	ID      model.ProductId
+	Status  repositories.UpsertStatus
+	Changes []model.FieldChange
+}
+
+// ImportBatchResult reports one batch of imported records. Products are the valid ones, Errors the rows that were rejected.
+// When Err is set, none of the products was stored.
+type ImportBatchResult struct {
+	// Seq is the position of the batch in the source, counted from zero.
+	Seq int
+	// LastLine is the line of the last record of the batch.
+	LastLine int
+	Records  int
+	Products []*model.Product
+	Result   repositories.UpsertResult
+	Errors   []RowError
+	// Diffs are only reported by a preview, for the products that would be created or updated.
+	Diffs []ProductDiff
+	// Archived counts the products archived by the delete rows of a delta.
+	Archived int
+	Err      error
+}
+
+// Rejected counts the rows that were not imported. A row can have several errors, so rows are told apart by their line.
+func (r *ImportBatchResult) Rejected() int {
+	lines := make(map[int]bool, len(r.Errors))
+	for _, err := range r.Errors {
+		lines[err.Line] = true
+	}
+	return len(lines)
+}
+
+func (r *ImportBatchResult) Count(status repositories.UpsertStatus) int {
+	count := 0
+	for _, s := range r.Result {
+		if s == status {
+			count++
+		}
+	}
+	return count
+}
+
 type CatalogImportService interface {
-	Store(ctx context.Context, products <-chan *model.Product) <-chan *model.Product
+	Store(ctx context.Context, records <-chan *SourceRecord) <-chan *ImportBatchResult
+	Preview(ctx context.Context, records <-chan *SourceRecord) <-chan *ImportBatchResult
+	Retire(ctx context.Context, seen map[model.ProductId]bool, maxRemovedPercent float64) (int, error)
+	ApplyDelta(ctx context.Context, records <-chan *SourceRecord) <-chan *ImportBatchResult
+	PreviewDelta(ctx context.Context, records <-chan *SourceRecord) <-chan *ImportBatchResult
+}
+
+// ImportSummary counts what happened to the records of an import.
+type ImportSummary struct {
+	Records   int
+	Created   int
+	Restored  int
+	Updated   int
+	Unchanged int
+	Invalid   int
+	Failed    int
+	Archived  int
+	Errors    []RowError
+	// Diffs samples the changes of a preview, up to DiffSampleSize of them.
+	Diffs          []ProductDiff
+	DiffSampleSize int
+}
+
+func (s *ImportSummary) Add(batch *ImportBatchResult) {
+	s.Records += batch.Records
+	s.Errors = append(s.Errors, batch.Errors...)
+	for _, diff := range batch.Diffs {
+		if len(s.Diffs) >= s.DiffSampleSize {
+			break
+		}
+		s.Diffs = append(s.Diffs, diff)
+	}
+	if batch.Err != nil {
+		s.Failed += len(batch.Products)
+		s.Invalid += batch.Rejected() - len(batch.Products)
+		return
+	}
+	s.Invalid += batch.Rejected()
+	s.Created += batch.Count(repositories.UpsertCreated)
+	s.Restored += batch.Count(repositories.UpsertRestored)
+	s.Updated += batch.Count(repositories.UpsertUpdated)
+	s.Unchanged += batch.Count(repositories.UpsertUnchanged)
+	s.Archived += batch.Archived
+}
+
+// ErrorRate is the percent of records that were not imported.
+func (s *ImportSummary) ErrorRate() float64 {
+	if s.Records == 0 {
+		return 0
+	}
+	return float64(s.Invalid+s.Failed) * 100 / float64(s.Records)
 }
 
 type catalogImportService struct {
-	repo repositories.CatalogWriter
+	repo      repositories.CatalogRepository
+	tx        repositories.Transactor
+	publisher ProductEventsPublisher
+	options   ImportPipelineOptions
 }
 
-func NewCatalogImportService(repo repositories.CatalogRepository) *catalogImportService {
+// NewCatalogImportService stores products together with their events, so publisher should be the outbox.
+func NewCatalogImportService(repo repositories.CatalogRepository, tx repositories.Transactor, publisher ProductEventsPublisher, options ImportPipelineOptions) *catalogImportService {
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultImportBatchSize
+	}
+	if options.Workers <= 0 {
+		options.Workers = DefaultImportWorkers
+	}
 	return &catalogImportService{
-		repo: repo,
+		repo:      repo,
+		tx:        tx,
+		publisher: publisher,
+		options:   options,
 	}
 }
 
-func filter(ctx context.Context, products <-chan *model.Product, predicate func(ctx context.Context, product *model.Product) bool) <-chan *model.Product {
-	filteredProducts := make(chan *model.Product, 100)
+// chunk groups records into batches of size. It stops reading when ctx is cancelled and drops the incomplete batch.
+func chunk(ctx context.Context, records <-chan *SourceRecord, size int) <-chan []*SourceRecord {
+	out := make(chan []*SourceRecord)
 	go func() {
-		for product := range products {
-			if predicate(ctx, product) {
-				filteredProducts <- product
+		defer close(out)
+		batch := make([]*SourceRecord, 0, size)
+		send := func() bool {
+			select {
+			case out <- batch:
+				batch = make([]*SourceRecord, 0, size)
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case record, ok := <-records:
+				if !ok {
+					if len(batch) > 0 {
+						send()
+					}
+					return
+				}
+				batch = append(batch, record)
+				if len(batch) == size && !send() {
+					return
+				}
 			}
 		}
-		close(filteredProducts)
 	}()
-	return filteredProducts
+	return out
 }
 
-func pipe(ctx context.Context, products <-chan *model.Product, f func(ctx context.Context, product *model.Product, stream chan<- *model.Product)) <-chan *model.Product {
-	out := make(chan *model.Product, 10)
-	go func() {
-		for product := range products {
-			f(ctx, product, out)
+func validateRecord(record *SourceRecord) []RowError {
+	if len(record.Errors) > 0 {
+		return record.Errors
+	}
+	if err := model.ValidateProduct(record.Product); err != nil {
+		return []RowError{{Line: record.Line, ProductID: record.Product.ID, Reason: err.Error()}}
+	}
+	return nil
+}
+
+func productIds(products []*model.Product) []model.ProductId {
+	ids := make([]model.ProductId, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+	return ids
+}
+
+func (s *catalogImportService) publishBatchEvents(ctx context.Context, products []*model.Product, current map[model.ProductId]*model.Product, result repositories.UpsertResult) error {
+	// When an id repeats in the batch, the last product is the one that was stored.
+	last := make(map[model.ProductId]int, len(products))
+	for i, product := range products {
+		last[product.ID] = i
+	}
+	for i, product := range products {
+		if last[product.ID] != i {
+			continue
 		}
-		close(out)
-	}()
-	return out
+		switch result[product.ID] {
+		case repositories.UpsertCreated, repositories.UpsertRestored:
+			// Consumers saw an archived product deleted, so a restored one comes back as a new product.
+			if err := s.publisher.PublishProductCreated(ctx, NewProductCreated(product)); err != nil {
+				return err
+			}
+		case repositories.UpsertUpdated:
+			old, ok := current[product.ID]
+			if !ok {
+				// The product was stored by another batch after current was read, it is new to consumers.
+				if err := s.publisher.PublishProductCreated(ctx, NewProductCreated(product)); err != nil {
+					return err
+				}
+				continue
+			}
+			if len(model.ChangedFields(old, product)) > 0 {
+				if err := s.publisher.PublishProductUpdated(ctx, NewProductUpdated(old, product)); err != nil {
+					return err
+				}
+			}
+			if model.PriceChanged(old, product) {
+				if err := s.publisher.PublishProductPriceChanged(ctx, NewProductPriceChanged(old, product)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
 }
 
-func (s *catalogImportService) Store(ctx context.Context, products <-chan *model.Product) <-chan *model.Product {
-	validProducts := filter(ctx, products, func(ctx context.Context, product *model.Product) bool {
-		err := model.ValidateProduct(product)
+// validateBatch returns the records that can be imported and reports the others in the batch errors.
+func validateBatch(records []*SourceRecord) (*ImportBatchResult, []*SourceRecord) {
+	batch := &ImportBatchResult{Records: len(records), Products: make([]*model.Product, 0, len(records))}
+	valid := make([]*SourceRecord, 0, len(records))
+	for _, record := range records {
+		if errs := validateRecord(record); len(errs) > 0 {
+			batch.Errors = append(batch.Errors, errs...)
+			continue
+		}
+		valid = append(valid, record)
+		batch.Products = append(batch.Products, record.Product)
+	}
+	return batch, valid
+}
+
+func (s *catalogImportService) currentProducts(ctx context.Context, products []*model.Product) (map[model.ProductId]*model.Product, error) {
+	existing, err := s.repo.GetProductByIds(ctx, productIds(products)...)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[model.ProductId]*model.Product, len(existing))
+	for _, product := range existing {
+		current[product.ID] = product
+	}
+	return current, nil
+}
+
+func (s *catalogImportService) storedProducts(ctx context.Context, products []*model.Product) (map[model.ProductId]*repositories.StoredProduct, error) {
+	return s.repo.GetStoredProducts(ctx, productIds(products)...)
+}
+
+func (s *catalogImportService) storeBatch(ctx context.Context, records []*SourceRecord) *ImportBatchResult {
+	batch, valid := validateBatch(records)
+	if len(batch.Products) == 0 {
+		return batch
+	}
+	batch.Err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		current, err := s.currentProducts(ctx, batch.Products)
+		if err != nil {
+			return err
+		}
+		result, err := s.repo.UpsertBatch(ctx, batch.Products)
 		if err != nil {
-			log.WithError(err).WithField("ProductId", product.ID).Errorln("Product is not valid")
-			return false
+			return err
 		}
-		return true
+		if err := s.publishBatchEvents(ctx, batch.Products, current, result); err != nil {
+			return err
+		}
+		batch.Result = result
+		return nil
 	})
+	if batch.Err != nil {
+		batch.Result = nil
+		for _, record := range valid {
+			batch.Errors = append(batch.Errors, RowError{Line: record.Line, ProductID: record.Product.ID, Reason: "can't store product: " + batch.Err.Error()})
+		}
+	}
+	return batch
+}
+
+// previewBatch compares the valid records with the stored products, archived ones included, the way UpsertBatch does.
+func (s *catalogImportService) previewBatch(ctx context.Context, records []*SourceRecord) *ImportBatchResult {
+	batch, valid := validateBatch(records)
+	if len(valid) == 0 {
+		return batch
+	}
+	stored, err := s.storedProducts(ctx, batch.Products)
+	if err != nil {
+		batch.Err = err
+		return batch
+	}
+	batch.Result = make(repositories.UpsertResult, len(valid))
+	for _, record := range valid {
+		diff := compareProduct(record, stored[record.Product.ID])
+		batch.Result[diff.ID] = diff.Status
+		if diff.Status != repositories.UpsertUnchanged {
+			batch.Diffs = append(batch.Diffs, diff)
+		}
+	}
+	return batch
+}
+
+// compareProduct tells what storing the record would do to the stored product, nil when there is none. Like UpsertBatch,
+// it decides by the content hash, so a product is only unchanged when it was stored from the same fields, brand spelling included.
+func compareProduct(record *SourceRecord, stored *repositories.StoredProduct) ProductDiff {
+	diff := ProductDiff{Line: record.Line, ID: record.Product.ID, Status: repositories.UpsertCreated}
+	if stored == nil {
+		return diff
+	}
+	diff.Changes = model.Changes(stored.Product, record.Product)
+	switch {
+	case stored.Archived:
+		diff.Status = repositories.UpsertRestored
+	case stored.ContentHash == model.ContentHash(record.Product):
+		diff.Status = repositories.UpsertUnchanged
+		diff.Changes = nil
+	default:
+		diff.Status = repositories.UpsertUpdated
+	}
+	return diff
+}
+
+type batchProcessor func(ctx context.Context, records []*SourceRecord) *ImportBatchResult
+
+// batchJob is a batch waiting for a worker, result gets the outcome so it can be collected in source order.
+type batchJob struct {
+	seq     int
+	records []*SourceRecord
+	result  chan *ImportBatchResult
+}
 
-	return pipe(ctx, validProducts, func(ctx context.Context, product *model.Product, out chan<- *model.Product) {
-		err := s.repo.Insert(ctx, product)
+// idGate keeps batches that share product ids from being processed at the same time. The transactions of two workers
+// storing the same product would otherwise race, an older version could be stored last, or deadlock on each other's rows.
+type idGate struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	busy    map[model.ProductId]bool
+}
+
+func newIdGate() *idGate {
+	gate := &idGate{busy: make(map[model.ProductId]bool)}
+	gate.changed = sync.NewCond(&gate.mu)
+	return gate
+}
+
+func batchIds(records []*SourceRecord) []model.ProductId {
+	ids := make([]model.ProductId, 0, len(records))
+	for _, record := range records {
+		if record.Product != nil {
+			ids = append(ids, record.Product.ID)
+		}
+	}
+	return ids
+}
+
+// enter waits until no batch in flight holds any of the ids, then holds them. The wait always ends, because
+// the batches in flight finish even when ctx is cancelled.
+func (g *idGate) enter(ids []model.ProductId) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for g.anyBusy(ids) {
+		g.changed.Wait()
+	}
+	for _, id := range ids {
+		g.busy[id] = true
+	}
+}
+
+func (g *idGate) anyBusy(ids []model.ProductId) bool {
+	for _, id := range ids {
+		if g.busy[id] {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *idGate) leave(ids []model.ProductId) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, id := range ids {
+		delete(g.busy, id)
+	}
+	g.changed.Broadcast()
+}
+
+// processBatches runs process on the batches with a pool of workers. At most Workers batches are in flight, so a slow
+// consumer of the results holds back the source. A batch sharing a product with a batch in flight waits for it before
+// it is handed to a worker, so batches are dispatched in source order and the later version of a product is stored last.
+// Batches still queued when ctx is cancelled are dropped, the batches being processed finish and their results are
+// delivered before the channel is closed.
+func (s *catalogImportService) processBatches(ctx context.Context, records <-chan *SourceRecord, process batchProcessor) <-chan *ImportBatchResult {
+	batches := chunk(ctx, records, s.options.BatchSize)
+	jobs := make(chan batchJob)
+	// pending keeps the result slots in source order, it is what bounds the batches in flight.
+	pending := make(chan chan *ImportBatchResult, s.options.Workers)
+	results := make(chan *ImportBatchResult, s.options.Workers)
+	gate := newIdGate()
+
+	go func() {
+		defer close(jobs)
+		defer close(pending)
+		seq := 0
+		for batch := range batches {
+			job := batchJob{seq: seq, records: batch, result: make(chan *ImportBatchResult, 1)}
+			seq++
+			pending <- job.result
+			gate.enter(batchIds(batch))
+			jobs <- job
+		}
+	}()
+
+	var workers sync.WaitGroup
+	workers.Add(s.options.Workers)
+	for i := 0; i < s.options.Workers; i++ {
+		go func() {
+			defer workers.Done()
+			for job := range jobs {
+				if ctx.Err() != nil {
+					gate.leave(batchIds(job.records))
+					close(job.result)
+					continue
+				}
+				result := process(ctx, job.records)
+				gate.leave(batchIds(job.records))
+				result.Seq = job.seq
+				result.LastLine = job.records[len(job.records)-1].Line
+				if s.options.Unordered {
+					results <- result
+				}
+				job.result <- result
+				close(job.result)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(results)
+		for slot := range pending {
+			result, ok := <-slot
+			if ok && !s.options.Unordered {
+				results <- result
+			}
+		}
+		workers.Wait()
+	}()
+	return results
+}
+
+// Store validates records and upserts their products in batches. Invalid records are reported in the batch errors,
+// products with unchanged content are skipped.
+// ProductCreated is published only for products that did not exist yet, changed products get ProductUpdated and,
+// when their price changed, ProductPriceChanged.
+func (s *catalogImportService) Store(ctx context.Context, records <-chan *SourceRecord) <-chan *ImportBatchResult {
+	return s.processBatches(ctx, records, s.storeBatch)
+}
+
+// Preview validates records and tells which products would be created, restored, updated or left unchanged, without writing
+// or publishing anything.
+func (s *catalogImportService) Preview(ctx context.Context, records <-chan *SourceRecord) <-chan *ImportBatchResult {
+	return s.processBatches(ctx, records, s.previewBatch)
+}
+
+// Retire archives every product the source did not yield and publishes ProductDeleted for it. It fails with
+// ErrSyncThresholdExceeded, without archiving anything, when more than maxRemovedPercent of the catalog would be removed.
+func (s *catalogImportService) Retire(ctx context.Context, seen map[model.ProductId]bool, maxRemovedPercent float64) (int, error) {
+	retired := 0
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		ids, err := s.repo.GetProductIds(ctx)
 		if err != nil {
-			log.Errorf("Error storing product: %v", err)
-		} else {
-			out <- product
+			return err
+		}
+		missing := make([]model.ProductId, 0)
+		for _, id := range ids {
+			if !seen[id] {
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+		if removed := float64(len(missing)) * 100 / float64(len(ids)); removed > maxRemovedPercent {
+			return fmt.Errorf("%w: %d of %d products (%.1f%%), the limit is %.1f%%", coreerr.ErrSyncThresholdExceeded, len(missing), len(ids), removed, maxRemovedPercent)
+		}
+		if err := s.repo.Archive(ctx, missing...); err != nil {
+			return err
+		}
+		for _, id := range missing {
+			if err := s.publisher.PublishProductDeleted(ctx, NewProductDeleted(id)); err != nil {
+				return err
+			}
 		}
+		retired = len(missing)
+		return nil
 	})
+	return retired, err
 }
 
+// ProductsSourceDataProvider streams the records of a source. The records channel is closed when the source is read
+// to the end, when it can't be read any further or when ctx is cancelled. The error channel then yields the error
+// that stopped the source, if any, and is closed as well.
 type ProductsSourceDataProvider interface {
-	Provide(ctx context.Context) <-chan *model.Product
+	Provide(ctx context.Context) (<-chan *SourceRecord, <-chan error)
 }
@@ -0,0 +1,415 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	coreerr "github.com/micro-eshop/catalog/pkg/core/error"
+	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeCatalogRepository struct {
+	repositories.CatalogRepository
+	mu       sync.Mutex
+	products map[model.ProductId]*model.Product
+	archived map[model.ProductId]*model.Product
+	batches  [][]*model.Product
+	// delay slows down storing a batch, to let batches overtake each other.
+	delay func(products []*model.Product) time.Duration
+}
+
+func (r *fakeCatalogRepository) GetProductByIds(ctx context.Context, ids ...model.ProductId) ([]*model.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	products := make([]*model.Product, 0)
+	for _, id := range ids {
+		if product, ok := r.products[id]; ok {
+			copy := *product
+			products = append(products, &copy)
+		}
+	}
+	return products, nil
+}
+
+func (r *fakeCatalogRepository) GetStoredProducts(ctx context.Context, ids ...model.ProductId) (map[model.ProductId]*repositories.StoredProduct, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := make(map[model.ProductId]*repositories.StoredProduct)
+	for _, id := range ids {
+		if product, ok := r.products[id]; ok {
+			stored[id] = &repositories.StoredProduct{Product: product, ContentHash: model.ContentHash(product)}
+		} else if product, ok := r.archived[id]; ok {
+			stored[id] = &repositories.StoredProduct{Product: product, ContentHash: model.ContentHash(product), Archived: true}
+		}
+	}
+	return stored, nil
+}
+
+func (r *fakeCatalogRepository) UpsertBatch(ctx context.Context, products []*model.Product) (repositories.UpsertResult, error) {
+	if r.delay != nil {
+		time.Sleep(r.delay(products))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, products)
+	result := make(repositories.UpsertResult)
+	for _, product := range products {
+		current, ok := r.products[product.ID]
+		_, archived := r.archived[product.ID]
+		switch {
+		case archived:
+			result[product.ID] = repositories.UpsertRestored
+			delete(r.archived, product.ID)
+		case !ok:
+			result[product.ID] = repositories.UpsertCreated
+		case model.ContentHash(current) == model.ContentHash(product):
+			result[product.ID] = repositories.UpsertUnchanged
+		default:
+			result[product.ID] = repositories.UpsertUpdated
+		}
+		r.products[product.ID] = product
+	}
+	return result, nil
+}
+
+func (r *fakeCatalogRepository) GetProductIds(ctx context.Context) ([]model.ProductId, error) {
+	ids := make([]model.ProductId, 0, len(r.products))
+	for id := range r.products {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (r *fakeCatalogRepository) Archive(ctx context.Context, ids ...model.ProductId) error {
+	if r.archived == nil {
+		r.archived = make(map[model.ProductId]*model.Product)
+	}
+	for _, id := range ids {
+		if product, ok := r.products[id]; ok {
+			r.archived[id] = product
+			delete(r.products, id)
+		}
+	}
+	return nil
+}
+
+type fakeEventsPublisher struct {
+	mu           sync.Mutex
+	created      []int
+	updated      []int
+	priceChanged []int
+	deleted      []int
+}
+
+func (p *fakeEventsPublisher) PublishProductCreated(ctx context.Context, event ProductCreated) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.created = append(p.created, event.ID)
+	return nil
+}
+
+func (p *fakeEventsPublisher) PublishProductUpdated(ctx context.Context, event ProductUpdated) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.updated = append(p.updated, event.ID)
+	return nil
+}
+
+func (p *fakeEventsPublisher) PublishProductDeleted(ctx context.Context, event ProductDeleted) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.deleted = append(p.deleted, event.ID)
+	return nil
+}
+
+func (p *fakeEventsPublisher) PublishProductPriceChanged(ctx context.Context, event ProductPriceChanged) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.priceChanged = append(p.priceChanged, event.ID)
+	return nil
+}
+
+func TestStoreInBatches(t *testing.T) {
+	repo := &fakeCatalogRepository{products: map[model.ProductId]*model.Product{
+		2: model.NewProduct(model.ProductId(2), "name", "brand", "description", 2.0),
+		3: model.NewProduct(model.ProductId(3), "name", "brand", "description", 1.0),
+		4: model.NewProduct(model.ProductId(4), "old name", "brand", "description", 1.0),
+	}}
+	publisher := &fakeEventsPublisher{}
+	service := NewCatalogImportService(repo, fakeTransactor{}, publisher, ImportPipelineOptions{BatchSize: 2})
+
+	records := make(chan *SourceRecord, 10)
+	for i := 1; i <= 5; i++ {
+		records <- &SourceRecord{Line: i + 1, Product: model.NewProduct(model.ProductId(i), "name", "brand", "description", 1.0)}
+	}
+	records <- &SourceRecord{Line: 7, Product: model.NewProduct(model.ProductId(6), "", "brand", "description", 1.0)}
+	parseError := RowError{Line: 8, Column: "Price", Value: "abc", Reason: "not a number"}
+	records <- &SourceRecord{Line: 8, Product: model.NewProduct(model.ProductId(7), "name", "brand", "description", 0), Errors: []RowError{parseError}}
+	close(records)
+
+	summary := &ImportSummary{}
+	for batch := range service.Store(context.Background(), records) {
+		assert.Nil(t, batch.Err)
+		summary.Add(batch)
+	}
+	assert.Len(t, repo.batches, 3)
+	expected := &ImportSummary{Records: 7, Created: 2, Updated: 2, Unchanged: 1, Invalid: 2, Errors: []RowError{
+		{Line: 7, ProductID: model.ProductId(6), Reason: "Name can't be empty"},
+		parseError,
+	}}
+	assert.Equal(t, expected, summary)
+	assert.InDelta(t, 28.57, summary.ErrorRate(), 0.01)
+	assert.Equal(t, []int{1, 5}, publisher.created)
+	assert.Equal(t, []int{2, 4}, publisher.updated)
+	assert.Equal(t, []int{2}, publisher.priceChanged)
+}
+
+func TestImportSummaryCountsRejectedRows(t *testing.T) {
+	summary := &ImportSummary{}
+	summary.Add(&ImportBatchResult{Records: 2, Result: repositories.UpsertResult{1: repositories.UpsertCreated}, Errors: []RowError{
+		{Line: 3, Column: "ProductId", Value: "x", Reason: "not a number"},
+		{Line: 3, Column: "Price", Value: "abc", Reason: "not a number"},
+	}})
+	summary.Add(&ImportBatchResult{Records: 2, Products: []*model.Product{model.NewProduct(model.ProductId(5), "name", "brand", "description", 1.0)}, Err: assert.AnError, Errors: []RowError{
+		{Line: 4, Column: "Name", Reason: "Name can't be empty"},
+		{Line: 4, Column: "Price", Value: "-1", Reason: "Price can't be negative"},
+		{Line: 5, ProductID: model.ProductId(5), Reason: "can't store product"},
+	}})
+	assert.Equal(t, 1, summary.Created)
+	assert.Equal(t, 2, summary.Invalid)
+	assert.Equal(t, 1, summary.Failed)
+	assert.Equal(t, 75.0, summary.ErrorRate())
+}
+
+func TestStoreWithWorkers(t *testing.T) {
+	repo := &fakeCatalogRepository{products: map[model.ProductId]*model.Product{}}
+	publisher := &fakeEventsPublisher{}
+	service := NewCatalogImportService(repo, fakeTransactor{}, publisher, ImportPipelineOptions{BatchSize: 3, Workers: 4})
+
+	records := make(chan *SourceRecord)
+	go func() {
+		for i := 1; i <= 50; i++ {
+			records <- &SourceRecord{Line: i + 1, Product: model.NewProduct(model.ProductId(i), "name", "brand", "description", 1.0)}
+		}
+		close(records)
+	}()
+
+	ids := make([]model.ProductId, 0, 50)
+	for batch := range service.Store(context.Background(), records) {
+		assert.Nil(t, batch.Err)
+		ids = append(ids, productIds(batch.Products)...)
+	}
+	assert.Len(t, ids, 50)
+	for i, id := range ids {
+		assert.Equal(t, model.ProductId(i+1), id)
+	}
+	assert.Len(t, repo.batches, 17)
+	assert.Len(t, publisher.created, 50)
+}
+
+func TestStoreWithWorkersWhenProductRepeats(t *testing.T) {
+	for _, unordered := range []bool{false, true} {
+		repo := &fakeCatalogRepository{products: map[model.ProductId]*model.Product{}, delay: func(products []*model.Product) time.Duration {
+			if products[0].Price == 1.0 {
+				return 50 * time.Millisecond
+			}
+			return 0
+		}}
+		service := NewCatalogImportService(repo, fakeTransactor{}, &fakeEventsPublisher{}, ImportPipelineOptions{BatchSize: 1, Workers: 2, Unordered: unordered})
+		records := make(chan *SourceRecord, 2)
+		records <- &SourceRecord{Line: 2, Product: model.NewProduct(model.ProductId(1), "name", "brand", "description", 1.0)}
+		records <- &SourceRecord{Line: 3, Product: model.NewProduct(model.ProductId(1), "name", "brand", "description", 2.0)}
+		close(records)
+		for batch := range service.Store(context.Background(), records) {
+			assert.Nil(t, batch.Err)
+		}
+		assert.Equal(t, 2.0, repo.products[1].Price, "unordered: %v", unordered)
+	}
+}
+
+func TestStoreWhenCancelled(t *testing.T) {
+	repo := &fakeCatalogRepository{products: map[model.ProductId]*model.Product{}}
+	service := NewCatalogImportService(repo, fakeTransactor{}, &fakeEventsPublisher{}, ImportPipelineOptions{BatchSize: 2, Workers: 2, Unordered: true})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The source never ends, the pipeline has to stop because of the cancellation.
+	records := make(chan *SourceRecord)
+	for batch := range service.Store(ctx, records) {
+		t.Fatalf("no batch expected, got %d products", len(batch.Products))
+	}
+	assert.Empty(t, repo.batches)
+}
+
+func TestRetire(t *testing.T) {
+	newRepository := func() *fakeCatalogRepository {
+		products := make(map[model.ProductId]*model.Product)
+		for i := 1; i <= 10; i++ {
+			products[model.ProductId(i)] = model.NewProduct(model.ProductId(i), "name", "brand", "description", 1.0)
+		}
+		return &fakeCatalogRepository{products: products}
+	}
+	seen := map[model.ProductId]bool{1: true, 2: true, 3: true, 4: true, 5: true, 6: true, 7: true, 8: true}
+
+	t.Run("when below threshold", func(t *testing.T) {
+		repo := newRepository()
+		publisher := &fakeEventsPublisher{}
+		retired, err := NewCatalogImportService(repo, fakeTransactor{}, publisher, ImportPipelineOptions{}).Retire(context.Background(), seen, 20)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, retired)
+		assert.Len(t, repo.products, 8)
+		assert.ElementsMatch(t, []int{9, 10}, publisher.deleted)
+	})
+
+	t.Run("when above threshold", func(t *testing.T) {
+		repo := newRepository()
+		publisher := &fakeEventsPublisher{}
+		retired, err := NewCatalogImportService(repo, fakeTransactor{}, publisher, ImportPipelineOptions{}).Retire(context.Background(), seen, 10)
+		assert.ErrorIs(t, err, coreerr.ErrSyncThresholdExceeded)
+		assert.Zero(t, retired)
+		assert.Len(t, repo.products, 10)
+		assert.Empty(t, publisher.deleted)
+	})
+}
+
+func TestPreview(t *testing.T) {
+	repo := &fakeCatalogRepository{products: map[model.ProductId]*model.Product{
+		2: model.NewProduct(model.ProductId(2), "name", ".net", "description", 2.0),
+		3: model.NewProduct(model.ProductId(3), "name", ".net", "description", 1.0),
+		5: model.NewProduct(model.ProductId(5), "name", ".NET", "description", 1.0),
+	}, archived: map[model.ProductId]*model.Product{
+		6: model.NewProduct(model.ProductId(6), "name", ".net", "description", 1.0),
+	}}
+	publisher := &fakeEventsPublisher{}
+	service := NewCatalogImportService(repo, fakeTransactor{}, publisher, ImportPipelineOptions{})
+
+	records := make(chan *SourceRecord, 10)
+	for i := 1; i <= 3; i++ {
+		records <- &SourceRecord{Line: i + 1, Product: model.NewProduct(model.ProductId(i), "name", ".net", "description", 1.0)}
+	}
+	records <- &SourceRecord{Line: 5, Product: model.NewProduct(model.ProductId(4), "", "brand", "description", 1.0)}
+	records <- &SourceRecord{Line: 6, Product: model.NewProduct(model.ProductId(5), "name", ".net", "description", 1.0)}
+	records <- &SourceRecord{Line: 7, Product: model.NewProduct(model.ProductId(6), "name", ".net", "description", 1.0)}
+	close(records)
+
+	summary := &ImportSummary{DiffSampleSize: 10}
+	for batch := range service.Preview(context.Background(), records) {
+		assert.Nil(t, batch.Err)
+		summary.Add(batch)
+	}
+	assert.Empty(t, repo.batches)
+	assert.Empty(t, publisher.created)
+	assert.Equal(t, 1, summary.Created)
+	assert.Equal(t, 1, summary.Restored)
+	assert.Equal(t, 2, summary.Updated)
+	assert.Equal(t, 1, summary.Unchanged)
+	assert.Equal(t, 1, summary.Invalid)
+	assert.Equal(t, []ProductDiff{
+		{Line: 2, ID: model.ProductId(1), Status: repositories.UpsertCreated},
+		{Line: 3, ID: model.ProductId(2), Status: repositories.UpsertUpdated, Changes: []model.FieldChange{{Field: "price", Old: 2.0, New: 1.0}}},
+		{Line: 6, ID: model.ProductId(5), Status: repositories.UpsertUpdated, Changes: []model.FieldChange{{Field: "brand", Old: ".NET", New: ".net"}}},
+		{Line: 7, ID: model.ProductId(6), Status: repositories.UpsertRestored, Changes: []model.FieldChange{}},
+	}, summary.Diffs)
+}
+
+func TestApplyDelta(t *testing.T) {
+	newRepository := func() *fakeCatalogRepository {
+		return &fakeCatalogRepository{products: map[model.ProductId]*model.Product{
+			1: model.NewProduct(model.ProductId(1), "name", "brand", "description", 1.0),
+			2: model.NewProduct(model.ProductId(2), "name", "brand", "description", 1.0),
+		}}
+	}
+	apply := func(repo *fakeCatalogRepository, publisher *fakeEventsPublisher, records ...*SourceRecord) (*ImportSummary, error) {
+		source := make(chan *SourceRecord, len(records))
+		for _, record := range records {
+			source <- record
+		}
+		close(source)
+		summary := &ImportSummary{}
+		var err error
+		for batch := range NewCatalogImportService(repo, fakeTransactor{}, publisher, ImportPipelineOptions{}).ApplyDelta(context.Background(), source) {
+			summary.Add(batch)
+			err = batch.Err
+		}
+		return summary, err
+	}
+
+	t.Run("in file order", func(t *testing.T) {
+		repo := newRepository()
+		publisher := &fakeEventsPublisher{}
+		summary, err := apply(repo, publisher,
+			&SourceRecord{Line: 2, Op: DeltaUpsert, Product: model.NewProduct(model.ProductId(3), "name", "brand", "description", 1.0)},
+			&SourceRecord{Line: 3, Op: DeltaUpdate, Product: model.NewProduct(model.ProductId(3), "name", "brand", "description", 2.0)},
+			&SourceRecord{Line: 4, Op: DeltaDelete, Product: &model.Product{ID: model.ProductId(1)}},
+			&SourceRecord{Line: 5, Op: DeltaDelete, Product: &model.Product{ID: model.ProductId(9)}},
+		)
+		assert.Nil(t, err)
+		assert.Nil(t, summary.Errors)
+		assert.Equal(t, 1, summary.Created)
+		assert.Equal(t, 1, summary.Archived)
+		assert.Equal(t, 2.0, repo.products[3].Price)
+		assert.NotContains(t, repo.products, model.ProductId(1))
+		assert.Equal(t, []int{3}, publisher.created)
+		assert.Equal(t, []int{3}, publisher.priceChanged)
+		assert.Equal(t, []int{1}, publisher.deleted)
+	})
+
+	t.Run("in batches split at deletes", func(t *testing.T) {
+		repo := newRepository()
+		publisher := &fakeEventsPublisher{}
+		summary, err := apply(repo, publisher,
+			&SourceRecord{Line: 2, Op: DeltaUpsert, Product: model.NewProduct(model.ProductId(3), "name", "brand", "description", 1.0)},
+			&SourceRecord{Line: 3, Op: DeltaUpsert, Product: model.NewProduct(model.ProductId(2), "name", "brand", "description", 1.0)},
+			&SourceRecord{Line: 4, Op: DeltaUpdate, Product: model.NewProduct(model.ProductId(3), "name", "brand", "description", 2.0)},
+			&SourceRecord{Line: 5, Op: DeltaDelete, Product: &model.Product{ID: model.ProductId(1)}},
+			&SourceRecord{Line: 6, Op: DeltaUpsert, Product: model.NewProduct(model.ProductId(1), "name", "brand", "description", 1.0)},
+		)
+		assert.Nil(t, err)
+		stored := make([][]model.ProductId, 0, len(repo.batches))
+		for _, products := range repo.batches {
+			ids := make([]model.ProductId, 0, len(products))
+			for _, product := range products {
+				ids = append(ids, product.ID)
+			}
+			stored = append(stored, ids)
+		}
+		assert.Equal(t, [][]model.ProductId{{3, 2}, {3}, {1}}, stored)
+		assert.Equal(t, 1, summary.Created)
+		assert.Equal(t, 1, summary.Restored)
+		assert.Equal(t, 1, summary.Unchanged)
+		assert.Equal(t, 2.0, repo.products[3].Price)
+		assert.Equal(t, []int{3, 1}, publisher.created)
+		assert.Equal(t, []int{1}, publisher.deleted)
+	})
+
+	t.Run("when a row can't be parsed", func(t *testing.T) {
+		repo := newRepository()
+		publisher := &fakeEventsPublisher{}
+		summary, err := apply(repo, publisher,
+			&SourceRecord{Line: 2, Op: DeltaDelete, Product: &model.Product{ID: model.ProductId(1)}},
+			&SourceRecord{Line: 3, Errors: []RowError{{Line: 3, Reason: "wrong number of fields"}}},
+		)
+		assert.ErrorIs(t, err, coreerr.ErrDeltaRejected)
+		assert.Equal(t, RowError{Line: 3, Reason: "wrong number of fields"}, summary.Errors[0])
+		assert.Len(t, repo.products, 2)
+		assert.Empty(t, publisher.deleted)
+	})
+
+	t.Run("when a product to update does not exist", func(t *testing.T) {
+		repo := newRepository()
+		publisher := &fakeEventsPublisher{}
+		summary, err := apply(repo, publisher,
+			&SourceRecord{Line: 2, Op: DeltaDelete, Product: &model.Product{ID: model.ProductId(1)}},
+			&SourceRecord{Line: 3, Op: DeltaUpdate, Product: model.NewProduct(model.ProductId(9), "name", "brand", "description", 1.0)},
+		)
+		assert.ErrorIs(t, err, coreerr.ErrDeltaRejected)
+		assert.Equal(t, RowError{Line: 3, ProductID: model.ProductId(9), Column: "op", Value: "update", Reason: "product not found"}, summary.Errors[0])
+		assert.Len(t, repo.products, 2)
+		assert.Empty(t, repo.batches)
+		assert.Empty(t, publisher.deleted)
+	})
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+
+	coreerr "github.com/micro-eshop/catalog/pkg/core/error"
+	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+)
+
+type CategoryService interface {
+	GetCategoryTree(ctx context.Context) ([]*model.Category, error)
+	GetCategoryProducts(ctx context.Context, id model.CategoryId, params repositories.ProductSearchParams) (*repositories.ProductsPage, error)
+	GetCategoryFacets(ctx context.Context, id model.CategoryId, params repositories.ProductSearchParams) (*repositories.ProductFacets, error)
+}
+
+type categoryService struct {
+	repo    repositories.CategoryReader
+	catalog CatalogService
+}
+
+func NewCategoryService(repo repositories.CategoryReader, catalog CatalogService) *categoryService {
+	return &categoryService{
+		repo:    repo,
+		catalog: catalog,
+	}
+}
+
+func (s *categoryService) GetCategoryTree(ctx context.Context) ([]*model.Category, error) {
+	categories, err := s.repo.GetCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return model.BuildCategoryTree(categories), nil
+}
+
+func (s *categoryService) ensureCategoryExists(ctx context.Context, id model.CategoryId) error {
+	err := model.ValidateCategoryId(id)
+	if err != nil {
+		return err
+	}
+	category, err := s.repo.GetCategoryById(ctx, id)
+	if err != nil {
+		return err
+	}
+	if category == nil {
+		return coreerr.ErrCategoryNotFound
+	}
+	return nil
+}
+
+func (s *categoryService) GetCategoryProducts(ctx context.Context, id model.CategoryId, params repositories.ProductSearchParams) (*repositories.ProductsPage, error) {
+	if err := s.ensureCategoryExists(ctx, id); err != nil {
+		return nil, err
+	}
+	params.CategoryID = id
+	return s.catalog.Search(ctx, params)
+}
+
+func (s *categoryService) GetCategoryFacets(ctx context.Context, id model.CategoryId, params repositories.ProductSearchParams) (*repositories.ProductFacets, error) {
+	params.CategoryID = id
+	return s.catalog.Facets(ctx, params)
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	coreerr "github.com/micro-eshop/catalog/pkg/core/error"
+	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+)
+
+// DeltaOp is what a row of a delta feed does to its product.
+type DeltaOp string
+
+const (
+	// DeltaUpsert creates the product or updates it.
+	DeltaUpsert DeltaOp = "upsert"
+	// DeltaUpdate updates a product that has to exist already.
+	DeltaUpdate DeltaOp = "update"
+	// DeltaDelete archives the product, a product that does not exist is skipped.
+	DeltaDelete DeltaOp = "delete"
+)
+
+// deltaStep is a row of a delta with the state of its product just before the row applies.
+type deltaStep struct {
+	record *SourceRecord
+	old    *model.Product
+	diff   ProductDiff
+}
+
+// validateDeltaRecord rejects a row that could not be parsed before looking at its op, such a row may have no product.
+func validateDeltaRecord(record *SourceRecord) []RowError {
+	if len(record.Errors) > 0 {
+		return record.Errors
+	}
+	switch record.Op {
+	case DeltaUpsert, DeltaUpdate:
+		return validateRecord(record)
+	case DeltaDelete:
+		return nil
+	default:
+		rowError := RowError{Line: record.Line, Column: "op", Value: string(record.Op), Reason: "unknown op, expected upsert, update or delete"}
+		if record.Product != nil {
+			rowError.ProductID = record.Product.ID
+		}
+		return []RowError{rowError}
+	}
+}
+
+// mergeUpsertStatus tells what the rows of a delta did to a product, previous is what the earlier rows did.
+// A product created or restored by an earlier row still counts as such.
+func mergeUpsertStatus(previous, next repositories.UpsertStatus) repositories.UpsertStatus {
+	if previous == repositories.UpsertCreated || previous == repositories.UpsertRestored {
+		return previous
+	}
+	return next
+}
+
+// planDelta validates the rows and replays them in file order against the current catalog, so a row sees the products
+// created or deleted by the rows before it. A row updating an unknown product is rejected.
+func (s *catalogImportService) planDelta(ctx context.Context, records []*SourceRecord) (*ImportBatchResult, []deltaStep) {
+	batch := &ImportBatchResult{Records: len(records), Result: make(repositories.UpsertResult), Products: make([]*model.Product, 0, len(records))}
+	if len(records) > 0 {
+		batch.LastLine = records[len(records)-1].Line
+	}
+	valid := make([]*SourceRecord, 0, len(records))
+	products := make([]*model.Product, 0, len(records))
+	for _, record := range records {
+		if errs := validateDeltaRecord(record); len(errs) > 0 {
+			batch.Errors = append(batch.Errors, errs...)
+			continue
+		}
+		valid = append(valid, record)
+		products = append(products, record.Product)
+	}
+	state, err := s.storedProducts(ctx, products)
+	if err != nil {
+		batch.Err = err
+		return batch, nil
+	}
+
+	steps := make([]deltaStep, 0, len(valid))
+	for _, record := range valid {
+		id := record.Product.ID
+		stored := state[id]
+		exists := stored != nil && !stored.Archived
+		switch record.Op {
+		case DeltaDelete:
+			if !exists {
+				continue
+			}
+			state[id] = &repositories.StoredProduct{Product: stored.Product, ContentHash: stored.ContentHash, Archived: true}
+			batch.Archived++
+		case DeltaUpdate:
+			if !exists {
+				batch.Errors = append(batch.Errors, RowError{Line: record.Line, ProductID: id, Column: "op", Value: string(record.Op), Reason: "product not found"})
+				continue
+			}
+			fallthrough
+		default:
+			state[id] = &repositories.StoredProduct{Product: record.Product, ContentHash: model.ContentHash(record.Product)}
+			batch.Products = append(batch.Products, record.Product)
+		}
+		step := deltaStep{record: record}
+		if exists {
+			step.old = stored.Product
+		}
+		if record.Op != DeltaDelete {
+			step.diff = compareProduct(record, stored)
+			batch.Result[id] = mergeUpsertStatus(batch.Result[id], step.diff.Status)
+			if step.diff.Status != repositories.UpsertUnchanged {
+				batch.Diffs = append(batch.Diffs, step.diff)
+			}
+		}
+		steps = append(steps, step)
+	}
+	return batch, steps
+}
+
+// rejectDelta marks every row that was not rejected itself as not applied, the delta is all or nothing.
+func rejectDelta(batch *ImportBatchResult, steps []deltaStep, err error) {
+	batch.Err = err
+	batch.Result = nil
+	batch.Diffs = nil
+	batch.Archived = 0
+	batch.Products = make([]*model.Product, 0, len(steps))
+	for _, step := range steps {
+		batch.Errors = append(batch.Errors, RowError{Line: step.record.Line, ProductID: step.record.Product.ID, Reason: "not applied: " + err.Error()})
+		batch.Products = append(batch.Products, step.record.Product)
+	}
+}
+
+// upsertDeltaSteps stores consecutive upsert and update rows with one UpsertBatch and publishes their events.
+func (s *catalogImportService) upsertDeltaSteps(ctx context.Context, steps []deltaStep) (repositories.UpsertResult, error) {
+	products := make([]*model.Product, 0, len(steps))
+	current := make(map[model.ProductId]*model.Product, len(steps))
+	for _, step := range steps {
+		products = append(products, step.record.Product)
+		if step.old != nil {
+			current[step.record.Product.ID] = step.old
+		}
+	}
+	result, err := s.repo.UpsertBatch(ctx, products)
+	if err != nil {
+		return nil, fmt.Errorf("can't apply lines %d to %d: %w", steps[0].record.Line, steps[len(steps)-1].record.Line, err)
+	}
+	return result, s.publishBatchEvents(ctx, products, current, result)
+}
+
+// applyDeltaSteps applies the rows in file order and returns what the repository did to the upserted products.
+// A delete, or a product repeating in the pending upserts, stores them first, so every row applies over the rows before it.
+func (s *catalogImportService) applyDeltaSteps(ctx context.Context, steps []deltaStep) (repositories.UpsertResult, error) {
+	applied := make(repositories.UpsertResult)
+	pending := make([]deltaStep, 0, len(steps))
+	ids := make(map[model.ProductId]bool, len(steps))
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		result, err := s.upsertDeltaSteps(ctx, pending)
+		if err != nil {
+			return err
+		}
+		for id, status := range result {
+			applied[id] = mergeUpsertStatus(applied[id], status)
+		}
+		pending = pending[:0]
+		ids = make(map[model.ProductId]bool, len(steps))
+		return nil
+	}
+	for _, step := range steps {
+		product := step.record.Product
+		if step.record.Op == DeltaDelete || ids[product.ID] {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+		if step.record.Op != DeltaDelete {
+			pending = append(pending, step)
+			ids[product.ID] = true
+			continue
+		}
+		if err := s.repo.Archive(ctx, product.ID); err != nil {
+			return nil, fmt.Errorf("can't apply line %d: %w", step.record.Line, err)
+		}
+		if err := s.publisher.PublishProductDeleted(ctx, NewProductDeleted(product.ID)); err != nil {
+			return nil, fmt.Errorf("can't apply line %d: %w", step.record.Line, err)
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+func (s *catalogImportService) applyDelta(ctx context.Context, records []*SourceRecord) *ImportBatchResult {
+	var batch *ImportBatchResult
+	var steps []deltaStep
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		batch, steps = s.planDelta(ctx, records)
+		if batch.Err != nil {
+			return batch.Err
+		}
+		if len(batch.Errors) > 0 {
+			return fmt.Errorf("%w: %d of %d rows are invalid", coreerr.ErrDeltaRejected, len(batch.Errors), len(records))
+		}
+		result, err := s.applyDeltaSteps(ctx, steps)
+		if err != nil {
+			return err
+		}
+		batch.Result = result
+		return nil
+	})
+	if err != nil {
+		rejectDelta(batch, steps, err)
+	}
+	return batch
+}
+
+func (s *catalogImportService) previewDelta(ctx context.Context, records []*SourceRecord) *ImportBatchResult {
+	batch, steps := s.planDelta(ctx, records)
+	if batch.Err == nil && len(batch.Errors) > 0 {
+		rejectDelta(batch, steps, fmt.Errorf("%w: %d of %d rows are invalid", coreerr.ErrDeltaRejected, len(batch.Errors), len(records)))
+	}
+	return batch
+}
+
+// processDelta reads the whole delta and processes it as a single batch, rows have to apply in file order.
+func processDelta(ctx context.Context, records <-chan *SourceRecord, process batchProcessor) <-chan *ImportBatchResult {
+	results := make(chan *ImportBatchResult, 1)
+	go func() {
+		defer close(results)
+		delta := make([]*SourceRecord, 0)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case record, ok := <-records:
+				if !ok {
+					if len(delta) > 0 {
+						results <- process(ctx, delta)
+					}
+					return
+				}
+				delta = append(delta, record)
+			}
+		}
+	}()
+	return results
+}
+
+// ApplyDelta applies the rows of a delta feed in file order, in one transaction, and publishes the matching
+// ProductCreated, ProductUpdated, ProductPriceChanged and ProductDeleted events. The whole delta is rejected with
+// ErrDeltaRejected when a row is invalid or updates a product that does not exist.
+func (s *catalogImportService) ApplyDelta(ctx context.Context, records <-chan *SourceRecord) <-chan *ImportBatchResult {
+	return processDelta(ctx, records, s.applyDelta)
+}
+
+// PreviewDelta tells what ApplyDelta would do, without writing or publishing anything.
+func (s *catalogImportService) PreviewDelta(ctx context.Context, records <-chan *SourceRecord) <-chan *ImportBatchResult {
+	return processDelta(ctx, records, s.previewDelta)
+}
@@ -6,6 +6,14 @@ import (
 	"github.com/micro-eshop/catalog/pkg/core/model"
 )
 
+// Event types are used to route messages, so consumers can tell the events apart without parsing the payload.
+const (
+	ProductCreatedEventType      = "ProductCreated"
+	ProductUpdatedEventType      = "ProductUpdated"
+	ProductDeletedEventType      = "ProductDeleted"
+	ProductPriceChangedEventType = "ProductPriceChanged"
+)
+
 type ProductCreated struct {
 	ID             int      `json:"id"`
 	Name           string   `json:"name"`
@@ -13,12 +21,56 @@ type ProductCreated struct {
 	Description    string   `json:"description"`
 	Price          float64  `json:"price"`
 	PromotionPrice *float64 `json:"promotion_price,omitempty"`
+	Category       string   `json:"category,omitempty"`
 }
 
 func NewProductCreated(p *model.Product) ProductCreated {
-	return ProductCreated{ID: int(p.ID), Name: p.Name, Brand: p.Brand, Description: p.Description, Price: p.Price, PromotionPrice: p.PromotionPrice}
+	return ProductCreated{ID: int(p.ID), Name: p.Name, Brand: p.Brand, Description: p.Description, Price: p.Price, PromotionPrice: p.PromotionPrice, Category: p.Category}
+}
+
+// ProductUpdated carries the full state of the product after the change together with the names of the fields that changed.
+type ProductUpdated struct {
+	ID             int      `json:"id"`
+	ChangedFields  []string `json:"changed_fields"`
+	Name           string   `json:"name"`
+	Brand          string   `json:"brand"`
+	Description    string   `json:"description"`
+	Price          float64  `json:"price"`
+	PromotionPrice *float64 `json:"promotion_price,omitempty"`
+	Category       string   `json:"category,omitempty"`
+}
+
+func NewProductUpdated(old, new *model.Product) ProductUpdated {
+	return ProductUpdated{ID: int(new.ID), ChangedFields: model.ChangedFields(old, new), Name: new.Name, Brand: new.Brand, Description: new.Description, Price: new.Price, PromotionPrice: new.PromotionPrice, Category: new.Category}
+}
+
+type ProductDeleted struct {
+	ID int `json:"id"`
+}
+
+func NewProductDeleted(id model.ProductId) ProductDeleted {
+	return ProductDeleted{ID: int(id)}
+}
+
+type ProductPriceChanged struct {
+	ID                int      `json:"id"`
+	OldPrice          float64  `json:"old_price"`
+	NewPrice          float64  `json:"new_price"`
+	OldPromotionPrice *float64 `json:"old_promotion_price,omitempty"`
+	NewPromotionPrice *float64 `json:"new_promotion_price,omitempty"`
+}
+
+func NewProductPriceChanged(old, new *model.Product) ProductPriceChanged {
+	return ProductPriceChanged{ID: int(new.ID), OldPrice: old.Price, NewPrice: new.Price, OldPromotionPrice: old.PromotionPrice, NewPromotionPrice: new.PromotionPrice}
 }
 
 type ProductCreatedPublisher interface {
 	PublishProductCreated(ctx context.Context, event ProductCreated) error
 }
+
+type ProductEventsPublisher interface {
+	ProductCreatedPublisher
+	PublishProductUpdated(ctx context.Context, event ProductUpdated) error
+	PublishProductDeleted(ctx context.Context, event ProductDeleted) error
+	PublishProductPriceChanged(ctx context.Context, event ProductPriceChanged) error
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultOutboxBatchSize = 100
+	DefaultOutboxInterval  = time.Second
+	// DefaultOutboxLease is how long claimed messages are hidden from other relays. A relay that dies while
+	// publishing leaves its messages to be claimed again once the lease ended.
+	DefaultOutboxLease  = 2 * time.Minute
+	maxOutboxRetryDelay = 5 * time.Minute
+)
+
+type MessagePublisher interface {
+	// PublishMessages publishes the messages and waits until the broker confirmed them. It returns the error of every
+	// message, in the order of the messages, nil for a confirmed message.
+	PublishMessages(ctx context.Context, messages []*repositories.OutboxMessage) []error
+}
+
+// OutboxRetryDelay doubles the delay with every failed attempt, starting at one second and capped at five minutes.
+func OutboxRetryDelay(attempts int) time.Duration {
+	delay := time.Second
+	for i := 0; i < attempts && delay < maxOutboxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxOutboxRetryDelay {
+		return maxOutboxRetryDelay
+	}
+	return delay
+}
+
+type outboxRelay struct {
+	outbox    repositories.OutboxRepository
+	tx        repositories.Transactor
+	publisher MessagePublisher
+	batchSize int
+	interval  time.Duration
+	lease     time.Duration
+}
+
+func NewOutboxRelay(outbox repositories.OutboxRepository, tx repositories.Transactor, publisher MessagePublisher) *outboxRelay {
+	return &outboxRelay{
+		outbox:    outbox,
+		tx:        tx,
+		publisher: publisher,
+		batchSize: DefaultOutboxBatchSize,
+		interval:  DefaultOutboxInterval,
+		lease:     DefaultOutboxLease,
+	}
+}
+
+// claim leases a batch of pending messages in a short transaction, so no transaction stays open while the broker is called.
+func (r *outboxRelay) claim(ctx context.Context) ([]*repositories.OutboxMessage, error) {
+	var messages []*repositories.OutboxMessage
+	err := r.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		locked, err := r.outbox.TryLock(ctx)
+		if err != nil || !locked {
+			return err
+		}
+		messages, err = r.outbox.GetPending(ctx, r.batchSize)
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+		ids := make([]int64, len(messages))
+		for i, message := range messages {
+			ids[i] = message.ID
+		}
+		return r.outbox.Lease(ctx, time.Now().Add(r.lease), ids...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// outboxDelivery is what publishing a batch did to its messages.
+type outboxDelivery struct {
+	delivered []int64
+	failed    map[*repositories.OutboxMessage]error
+	// skipped are queued behind a failed message of the same product and were not published.
+	skipped []int64
+}
+
+// publish sends the messages in rounds holding at most one message per product, and waits for the confirmations of
+// a round before the next one, so the events of a product reach the broker in order. After a failed message,
+// the following messages of the same product are skipped.
+func (r *outboxRelay) publish(ctx context.Context, messages []*repositories.OutboxMessage) *outboxDelivery {
+	delivery := &outboxDelivery{failed: make(map[*repositories.OutboxMessage]error)}
+	blocked := make(map[model.ProductId]bool)
+	for len(messages) > 0 {
+		round := make([]*repositories.OutboxMessage, 0, len(messages))
+		next := make([]*repositories.OutboxMessage, 0)
+		queued := make(map[model.ProductId]bool)
+		for _, message := range messages {
+			switch {
+			case blocked[message.AggregateID]:
+				delivery.skipped = append(delivery.skipped, message.ID)
+			case queued[message.AggregateID]:
+				next = append(next, message)
+			default:
+				queued[message.AggregateID] = true
+				round = append(round, message)
+			}
+		}
+		for i, err := range r.publisher.PublishMessages(ctx, round) {
+			if err != nil {
+				log.WithContext(ctx).WithError(err).WithField("OutboxId", round[i].ID).Warnln("can't relay outbox message")
+				blocked[round[i].AggregateID] = true
+				delivery.failed[round[i]] = err
+				continue
+			}
+			delivery.delivered = append(delivery.delivered, round[i].ID)
+		}
+		messages = next
+	}
+	return delivery
+}
+
+// RelayBatch publishes one batch of pending messages and returns how many were delivered. Delivery is at least once:
+// a message is marked as delivered once the broker confirmed it. When a message fails, the following messages of the same product
+// wait until it is delivered.
+func (r *outboxRelay) RelayBatch(ctx context.Context) (int, error) {
+	messages, err := r.claim(ctx)
+	if err != nil || len(messages) == 0 {
+		return 0, err
+	}
+	delivery := r.publish(ctx, messages)
+	err = r.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		for _, id := range delivery.delivered {
+			if err := r.outbox.MarkDelivered(ctx, id); err != nil {
+				return err
+			}
+		}
+		for message, err := range delivery.failed {
+			if err := r.outbox.MarkFailed(ctx, message.ID, err.Error(), time.Now().Add(OutboxRetryDelay(message.Attempts))); err != nil {
+				return err
+			}
+		}
+		if len(delivery.skipped) > 0 {
+			return r.outbox.Lease(ctx, time.Now(), delivery.skipped...)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(delivery.delivered), nil
+}
+
+// Run relays messages until ctx is cancelled. Full batches are drained right away, otherwise the relay waits for the next tick.
+func (r *outboxRelay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		delivered, err := r.RelayBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.WithContext(ctx).WithError(err).Errorln("can't relay outbox")
+		}
+		if err == nil && delivered >= r.batchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeTransactor struct{}
+
+func (fakeTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type fakeOutbox struct {
+	pending   []*repositories.OutboxMessage
+	leased    []int64
+	released  []int64
+	delivered []int64
+	failed    []int64
+}
+
+func (o *fakeOutbox) TryLock(ctx context.Context) (bool, error) { return true, nil }
+
+func (o *fakeOutbox) GetPending(ctx context.Context, limit int) ([]*repositories.OutboxMessage, error) {
+	return o.pending, nil
+}
+
+func (o *fakeOutbox) Lease(ctx context.Context, until time.Time, ids ...int64) error {
+	if until.After(time.Now()) {
+		o.leased = append(o.leased, ids...)
+	} else {
+		o.released = append(o.released, ids...)
+	}
+	return nil
+}
+
+func (o *fakeOutbox) MarkDelivered(ctx context.Context, id int64) error {
+	o.delivered = append(o.delivered, id)
+	return nil
+}
+
+func (o *fakeOutbox) MarkFailed(ctx context.Context, id int64, reason string, nextAttemptAt time.Time) error {
+	o.failed = append(o.failed, id)
+	return nil
+}
+
+type fakeMessagePublisher struct {
+	failing map[int64]bool
+	rounds  [][]int64
+}
+
+func (p *fakeMessagePublisher) PublishMessages(ctx context.Context, messages []*repositories.OutboxMessage) []error {
+	round := make([]int64, len(messages))
+	errs := make([]error, len(messages))
+	for i, message := range messages {
+		round[i] = message.ID
+		if p.failing[message.ID] {
+			errs[i] = errors.New("broker unavailable")
+		}
+	}
+	p.rounds = append(p.rounds, round)
+	return errs
+}
+
+func TestOutboxRetryDelay(t *testing.T) {
+	assert.Equal(t, time.Second, OutboxRetryDelay(0))
+	assert.Equal(t, 4*time.Second, OutboxRetryDelay(2))
+	assert.Equal(t, 5*time.Minute, OutboxRetryDelay(100))
+}
+
+func TestRelayBatchWhenMessageFails(t *testing.T) {
+	outbox := &fakeOutbox{pending: []*repositories.OutboxMessage{
+		{ID: 1, AggregateID: model.ProductId(1)},
+		{ID: 2, AggregateID: model.ProductId(2)},
+		{ID: 3, AggregateID: model.ProductId(1)},
+		{ID: 4, AggregateID: model.ProductId(2)},
+		{ID: 5, AggregateID: model.ProductId(3)},
+	}}
+	publisher := &fakeMessagePublisher{failing: map[int64]bool{1: true}}
+	relay := NewOutboxRelay(outbox, fakeTransactor{}, publisher)
+	delivered, err := relay.RelayBatch(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 3, delivered)
+	assert.Equal(t, [][]int64{{1, 2, 5}, {4}}, publisher.rounds)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, outbox.leased)
+	assert.Equal(t, []int64{2, 5, 4}, outbox.delivered)
+	assert.Equal(t, []int64{1}, outbox.failed)
+	assert.Equal(t, []int64{3}, outbox.released)
+}
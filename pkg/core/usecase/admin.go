@@ -0,0 +1,107 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/micro-eshop/catalog/pkg/core/dto"
+	coreerr "github.com/micro-eshop/catalog/pkg/core/error"
+	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/services"
+)
+
+type CreateProductUseCase struct {
+	service services.CatalogAdminService
+}
+
+func NewCreateProductUseCase(service services.CatalogAdminService) *CreateProductUseCase {
+	return &CreateProductUseCase{
+		service: service,
+	}
+}
+
+func (uc *CreateProductUseCase) Execute(ctx context.Context, product *dto.ProductDto) (*dto.ProductDto, error) {
+	newProduct := product.ToProduct()
+	if err := uc.service.CreateProduct(ctx, newProduct); err != nil {
+		return nil, err
+	}
+	return dto.NewProductDto(newProduct), nil
+}
+
+type UpdateProductUseCase struct {
+	service services.CatalogAdminService
+}
+
+func NewUpdateProductUseCase(service services.CatalogAdminService) *UpdateProductUseCase {
+	return &UpdateProductUseCase{
+		service: service,
+	}
+}
+
+func (uc *UpdateProductUseCase) Execute(ctx context.Context, id model.ProductId, product *dto.ProductDto) (*dto.ProductDto, error) {
+	if product.ID != 0 && model.ProductId(product.ID) != id {
+		return nil, fmt.Errorf("%w: product id can't be changed", coreerr.ErrInvalidProduct)
+	}
+	updated := product.ToProduct()
+	updated.ID = id
+	if err := uc.service.UpdateProduct(ctx, updated); err != nil {
+		return nil, err
+	}
+	return dto.NewProductDto(updated), nil
+}
+
+type PatchProductUseCase struct {
+	service services.CatalogAdminService
+}
+
+func NewPatchProductUseCase(service services.CatalogAdminService) *PatchProductUseCase {
+	return &PatchProductUseCase{
+		service: service,
+	}
+}
+
+// Execute applies a JSON Merge Patch to the current state of the product.
+func (uc *PatchProductUseCase) Execute(ctx context.Context, id model.ProductId, patch []byte) (*dto.ProductDto, error) {
+	current, err := uc.service.GetProductById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, coreerr.ErrProductNotFound
+	}
+	document, err := json.Marshal(dto.NewProductDto(current))
+	if err != nil {
+		return nil, err
+	}
+	patched, err := dto.MergePatch(document, patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", coreerr.ErrInvalidProduct, err)
+	}
+	var product dto.ProductDto
+	if err := json.Unmarshal(patched, &product); err != nil {
+		return nil, fmt.Errorf("%w: %s", coreerr.ErrInvalidProduct, err)
+	}
+	if model.ProductId(product.ID) != id {
+		return nil, fmt.Errorf("%w: product id can't be changed", coreerr.ErrInvalidProduct)
+	}
+	updated := product.ToProduct()
+	if err := uc.service.UpdateProduct(ctx, updated); err != nil {
+		return nil, err
+	}
+	return dto.NewProductDto(updated), nil
+}
+
+type DeleteProductUseCase struct {
+	service services.CatalogAdminService
+}
+
+func NewDeleteProductUseCase(service services.CatalogAdminService) *DeleteProductUseCase {
+	return &DeleteProductUseCase{
+		service: service,
+	}
+}
+
+func (uc *DeleteProductUseCase) Execute(ctx context.Context, id model.ProductId) error {
+	return uc.service.DeleteProduct(ctx, id)
+}
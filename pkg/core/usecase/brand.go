@@ -0,0 +1,51 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/micro-eshop/catalog/pkg/core/dto"
+	"github.com/micro-eshop/catalog/pkg/core/services"
+)
+
+type GetBrandsUseCase struct {
+	service services.BrandService
+}
+
+func NewGetBrandsUseCase(service services.BrandService) *GetBrandsUseCase {
+	return &GetBrandsUseCase{
+		service: service,
+	}
+}
+
+func (uc *GetBrandsUseCase) Execute(ctx context.Context) ([]*dto.BrandDto, error) {
+	brands, err := uc.service.GetBrands(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*dto.BrandDto, len(brands))
+	for i, brand := range brands {
+		result[i] = dto.NewBrandDto(brand)
+	}
+	return result, nil
+}
+
+type GetBrandBySlugUseCase struct {
+	service services.BrandService
+}
+
+func NewGetBrandBySlugUseCase(service services.BrandService) *GetBrandBySlugUseCase {
+	return &GetBrandBySlugUseCase{
+		service: service,
+	}
+}
+
+func (uc *GetBrandBySlugUseCase) Execute(ctx context.Context, slug string) (*dto.BrandDto, error) {
+	brand, err := uc.service.GetBrandBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if brand == nil {
+		return nil, nil
+	}
+	return dto.NewBrandDto(brand), nil
+}
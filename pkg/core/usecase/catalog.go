@@ -44,16 +44,47 @@ func (uc *GetProductByIdsUseCase) Execute(ctx context.Context, ids []model.Produ
 	return uc.service.GetProductByIds(ctx, ids)
 }
 
-type searchProductsUseCase struct {
+type SearchProductsUseCase struct {
 	service services.CatalogService
 }
 
-func NewSearchProductsUseCase(service services.CatalogService) *searchProductsUseCase {
-	return &searchProductsUseCase{
+func NewSearchProductsUseCase(service services.CatalogService) *SearchProductsUseCase {
+	return &SearchProductsUseCase{
 		service: service,
 	}
 }
 
-func (uc *searchProductsUseCase) Execute(ctx context.Context, params repositories.ProductSearchParams) ([]*model.Product, error) {
-	return uc.service.Search(ctx, params)
+func (uc *SearchProductsUseCase) Execute(ctx context.Context, params repositories.ProductSearchParams, withFacets bool) (*dto.ProductsPageDto, error) {
+	page, err := uc.service.Search(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	result := dto.NewProductsPageDto(page.Products, page.Total, page.NextCursor)
+	if !withFacets {
+		return result, nil
+	}
+	facets, err := uc.service.Facets(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	result.Facets = dto.NewFacetsDto(facets)
+	return result, nil
+}
+
+type SuggestProductsUseCase struct {
+	service services.CatalogService
+}
+
+func NewSuggestProductsUseCase(service services.CatalogService) *SuggestProductsUseCase {
+	return &SuggestProductsUseCase{
+		service: service,
+	}
+}
+
+func (uc *SuggestProductsUseCase) Execute(ctx context.Context, prefix string, limit int) (*dto.SuggestionsDto, error) {
+	suggestions, err := uc.service.Suggest(ctx, prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+	return dto.NewSuggestionsDto(suggestions), nil
 }
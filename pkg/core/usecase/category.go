@@ -0,0 +1,55 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/micro-eshop/catalog/pkg/core/dto"
+	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+	"github.com/micro-eshop/catalog/pkg/core/services"
+)
+
+type GetCategoryTreeUseCase struct {
+	service services.CategoryService
+}
+
+func NewGetCategoryTreeUseCase(service services.CategoryService) *GetCategoryTreeUseCase {
+	return &GetCategoryTreeUseCase{
+		service: service,
+	}
+}
+
+func (uc *GetCategoryTreeUseCase) Execute(ctx context.Context) ([]*dto.CategoryDto, error) {
+	categories, err := uc.service.GetCategoryTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return dto.NewCategoryDtos(categories), nil
+}
+
+type GetCategoryProductsUseCase struct {
+	service services.CategoryService
+}
+
+func NewGetCategoryProductsUseCase(service services.CategoryService) *GetCategoryProductsUseCase {
+	return &GetCategoryProductsUseCase{
+		service: service,
+	}
+}
+
+func (uc *GetCategoryProductsUseCase) Execute(ctx context.Context, id model.CategoryId, params repositories.ProductSearchParams, withFacets bool) (*dto.ProductsPageDto, error) {
+	page, err := uc.service.GetCategoryProducts(ctx, id, params)
+	if err != nil {
+		return nil, err
+	}
+	result := dto.NewProductsPageDto(page.Products, page.Total, page.NextCursor)
+	if !withFacets {
+		return result, nil
+	}
+	facets, err := uc.service.GetCategoryFacets(ctx, id, params)
+	if err != nil {
+		return nil, err
+	}
+	result.Facets = dto.NewFacetsDto(facets)
+	return result, nil
+}
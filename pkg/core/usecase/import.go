@@ -2,34 +2,429 @@ package usecase
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
+	coreerr "github.com/micro-eshop/catalog/pkg/core/error"
+	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
 	"github.com/micro-eshop/catalog/pkg/core/services"
+	log "github.com/sirupsen/logrus"
 )
 
+const (
+	DefaultSyncMaxRemovedPercent = 10.0
+	DefaultMaxErrorRate          = 5.0
+	DefaultDiffSampleSize        = 10
+)
+
+type ImportOptions struct {
+	// Sync archives the products the source did not yield once every product was stored.
+	Sync bool
+	// SyncMaxRemovedPercent aborts the sync when it would archive a bigger part of the catalog.
+	SyncMaxRemovedPercent float64
+	// MaxErrorRate fails the import when a bigger percent of the records could not be imported.
+	MaxErrorRate float64
+	// DryRun validates and diffs the records against the catalog without writing anything. Sync is skipped.
+	DryRun bool
+	// Delta applies the op of every record in source order, all or nothing. Sync is skipped.
+	Delta bool
+	// DiffSampleSize limits how many diffs a dry run keeps in the summary.
+	DiffSampleSize int
+	// SourcePath and Checksum identify the source of the import run.
+	SourcePath string
+	Checksum   string
+	// Resume continues the last unfinished run of SourcePath from its checkpoint when the checksum still matches.
+	Resume bool
+	// RunID continues the given run from its checkpoint instead, the import fails when the checksum does not match.
+	RunID int64
+	// Owner identifies this process in the run, the run has to be claimed by it already when RunID is set.
+	// A token of its own is made up when empty.
+	Owner string
+}
+
 type importProductsUseCase struct {
-	service   services.CatalogImportService
-	source    services.ProductsSourceDataProvider
-	publisher services.ProductCreatedPublisher
+	service services.CatalogImportService
+	source  services.ProductsSourceDataProvider
+	runs    repositories.ImportRunRepository
+	options ImportOptions
 }
 
-func NewImportProductsUseCase(service services.CatalogImportService, source services.ProductsSourceDataProvider, publisher services.ProductCreatedPublisher) *importProductsUseCase {
+// NewImportProductsUseCase records the progress of the import in runs. Runs can be nil, the import is not tracked
+// and can't be resumed then.
+func NewImportProductsUseCase(service services.CatalogImportService, source services.ProductsSourceDataProvider, runs repositories.ImportRunRepository, options ImportOptions) *importProductsUseCase {
+	if options.Owner == "" {
+		options.Owner = newImportRunOwner()
+	}
 	return &importProductsUseCase{
-		service:   service,
-		source:    source,
-		publisher: publisher,
+		service: service,
+		source:  source,
+		runs:    runs,
+		options: options,
+	}
+}
+
+// recordIds passes records through and remembers their product ids, invalid records included,
+// so a product is never archived just because its row in the source is broken.
+func recordIds(ctx context.Context, records <-chan *services.SourceRecord, seen map[model.ProductId]bool) <-chan *services.SourceRecord {
+	out := make(chan *services.SourceRecord, 100)
+	go func() {
+		defer close(out)
+		for record := range records {
+			if record.Product != nil {
+				seen[record.Product.ID] = true
+			}
+			select {
+			case out <- record:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// skipCommitted drops the records a previous execution of the run already committed.
+func skipCommitted(ctx context.Context, records <-chan *services.SourceRecord, offset int) <-chan *services.SourceRecord {
+	out := make(chan *services.SourceRecord, 100)
+	go func() {
+		defer close(out)
+		for record := range records {
+			if record.Line <= offset {
+				continue
+			}
+			select {
+			case out <- record:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// wholeSource holds the records back until the source was read to the end and passes none of them on when it failed,
+// so a delta is never applied truncated.
+func wholeSource(ctx context.Context, records <-chan *services.SourceRecord, errs <-chan error) (<-chan *services.SourceRecord, <-chan error) {
+	out := make(chan *services.SourceRecord, 100)
+	outErrs := make(chan error, 1)
+	go func() {
+		defer close(outErrs)
+		defer close(out)
+		buffered := make([]*services.SourceRecord, 0)
+		for record := range records {
+			buffered = append(buffered, record)
+		}
+		if err := <-errs; err != nil {
+			outErrs <- err
+			return
+		}
+		for _, record := range buffered {
+			select {
+			case out <- record:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, outErrs
+}
+
+// appendRowErrors keeps at most MaxImportRunRowErrors rejected rows with the run.
+func appendRowErrors(run *repositories.ImportRun, rowErrors []services.RowError) {
+	if free := repositories.MaxImportRunRowErrors - len(run.RowErrors); free > 0 {
+		if len(rowErrors) < free {
+			free = len(rowErrors)
+		}
+		run.RowErrors = append(run.RowErrors, rowErrors[:free]...)
+	}
+}
+
+// checkpoint moves the run over the batches committed in source order. A batch that finishes before the ones
+// preceding it waits for them, a failed batch keeps the run from ever moving past it.
+type checkpoint struct {
+	run  *repositories.ImportRun
+	next int
+	done map[int]*services.ImportBatchResult
+}
+
+func newCheckpoint(run *repositories.ImportRun) *checkpoint {
+	return &checkpoint{run: run, done: make(map[int]*services.ImportBatchResult)}
+}
+
+// add returns true when the run moved and should be saved.
+func (c *checkpoint) add(batch *services.ImportBatchResult) bool {
+	if batch.Err != nil {
+		return false
+	}
+	c.done[batch.Seq] = batch
+	moved := false
+	for {
+		committed, ok := c.done[c.next]
+		if !ok {
+			return moved
+		}
+		delete(c.done, c.next)
+		c.next++
+		c.run.Offset = committed.LastLine
+		c.run.Records += committed.Records
+		c.run.Invalid += committed.Rejected()
+		c.run.Created += committed.Count(repositories.UpsertCreated)
+		c.run.Restored += committed.Count(repositories.UpsertRestored)
+		c.run.Updated += committed.Count(repositories.UpsertUpdated)
+		c.run.Unchanged += committed.Count(repositories.UpsertUnchanged)
+		appendRowErrors(c.run, committed.Errors)
+		moved = true
+	}
+}
+
+// continueRun starts the run with RunID. A run that can't continue is returned with the error, so it gets finished.
+func (uc *importProductsUseCase) continueRun(ctx context.Context) (*repositories.ImportRun, error) {
+	run, err := uc.runs.GetImportRun(ctx, uc.options.RunID)
+	if err != nil {
+		return nil, err
+	}
+	if run == nil {
+		return nil, fmt.Errorf("import run %d not found", uc.options.RunID)
+	}
+	if run.Owner != uc.options.Owner {
+		return run, coreerr.ErrImportRunLost
+	}
+	if run.CancelRequested {
+		return run, context.Canceled
+	}
+	if run.Checksum != uc.options.Checksum {
+		return run, fmt.Errorf("source of import run %d changed since it started", run.ID)
 	}
+	run.Status = repositories.ImportRunRunning
+	run.Error = ""
+	run.FinishedAt = nil
+	log.WithContext(ctx).WithFields(log.Fields{"RunId": run.ID, "Offset": run.Offset}).Infoln("Starting import run")
+	return run, uc.runs.SaveImportRun(ctx, run)
 }
 
-func (uc *importProductsUseCase) Execute(ctx context.Context) error {
-	data := uc.source.Provide(ctx)
-	stream := uc.service.Store(ctx, data)
+// startRun returns the run to resume or a new one, nil when runs are not tracked.
+func (uc *importProductsUseCase) startRun(ctx context.Context) (*repositories.ImportRun, error) {
+	if uc.runs == nil {
+		return nil, nil
+	}
+	if uc.options.RunID != 0 {
+		return uc.continueRun(ctx)
+	}
+	logger := log.WithContext(ctx).WithField("Source", uc.options.SourcePath)
+	if uc.options.Resume {
+		last, err := uc.runs.GetLastImportRun(ctx, uc.options.SourcePath)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case last == nil || last.Status == repositories.ImportRunCompleted:
+			logger.Infoln("No unfinished import run to resume, starting a new one")
+		case last.Checksum != uc.options.Checksum:
+			logger.WithField("RunId", last.ID).Warnln("Source changed since the last import run, starting a new one")
+		default:
+			// The run may still be executed by another process, it is only taken over once its heartbeat went stale.
+			claimed, err := uc.runs.ClaimImportRun(ctx, last.ID, uc.options.Owner, time.Now().Add(-ImportJobStaleAfter))
+			if err != nil {
+				return nil, err
+			}
+			if !claimed {
+				return nil, fmt.Errorf("import run %d is still running", last.ID)
+			}
+			last.Status = repositories.ImportRunRunning
+			last.Owner = uc.options.Owner
+			last.Error = ""
+			last.FinishedAt = nil
+			logger.WithFields(log.Fields{"RunId": last.ID, "Offset": last.Offset}).Infoln("Resuming import run")
+			return last, nil
+		}
+	}
+	run := &repositories.ImportRun{SourcePath: uc.options.SourcePath, Checksum: uc.options.Checksum, Status: repositories.ImportRunRunning, Owner: uc.options.Owner}
+	if err := uc.runs.CreateImportRun(ctx, run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// heartbeat keeps a run started from the command line from being taken over while it executes, until stop is called.
+// lost is called when another process took the run over anyway. Runs of import jobs are heartbeated by ImportJobs.
+func (uc *importProductsUseCase) heartbeat(ctx context.Context, id int64, lost func()) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(ImportJobHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				alive, err := uc.runs.HeartbeatImportRuns(ctx, uc.options.Owner, id)
+				switch {
+				case err != nil && ctx.Err() == nil:
+					log.WithContext(ctx).WithError(err).WithField("RunId", id).Warnln("can't heartbeat import run")
+				case err == nil:
+					if _, ok := alive[id]; !ok {
+						log.WithContext(ctx).WithField("RunId", id).Warnln("Import run was taken over by another process")
+						lost()
+						return
+					}
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// interrupted tells whether the job was stopped by its process shutting down rather than cancelled by a user,
+// a job is only cancelled after the cancel was requested in its run.
+func (uc *importProductsUseCase) interrupted(ctx context.Context, run *repositories.ImportRun, err error) bool {
+	if uc.options.RunID == 0 || !errors.Is(err, context.Canceled) {
+		return false
+	}
+	stored, getErr := uc.runs.GetImportRun(ctx, run.ID)
+	return getErr == nil && stored != nil && !stored.CancelRequested
+}
+
+func (uc *importProductsUseCase) finishRun(ctx context.Context, run *repositories.ImportRun, summary *services.ImportSummary, err error) error {
+	if ctx.Err() != nil {
+		// A cancelled import still records where it stopped.
+		ctx = context.Background()
+	}
+	if uc.interrupted(ctx, run, err) {
+		// The run stays running with its checkpoint, another process takes it over once it went stale.
+		return uc.runs.SaveImportRun(ctx, run)
+	}
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.Archived += summary.Archived
+	switch {
+	case err == nil:
+		run.Status = repositories.ImportRunCompleted
+	case errors.Is(err, context.Canceled):
+		run.Status = repositories.ImportRunCancelled
+		run.Error = err.Error()
+	default:
+		run.Status = repositories.ImportRunFailed
+		run.Failed += summary.Failed
+		run.Error = err.Error()
+	}
+	return uc.runs.SaveImportRun(ctx, run)
+}
+
+// Execute drains the import pipeline and counts created, updated and unchanged products. Product events are written
+// to the outbox together with each batch, the outbox relay delivers them to RabbitMQ. Rejected rows are collected
+// in the summary, the import fails with ErrImportErrorRateExceeded when there are too many of them. A source that can't
+// be read to the end fails the import after the records read so far were stored, sync is skipped then. A delta is
+// not applied at all then.
+// The progress is checkpointed in the import run after every committed batch, a resumed run skips the committed records
+// but still counts their ids as seen for the sync.
+func (uc *importProductsUseCase) Execute(ctx context.Context) (*services.ImportSummary, error) {
+	summary := &services.ImportSummary{DiffSampleSize: uc.options.DiffSampleSize}
+	run, err := uc.startRun(ctx)
+	switch {
+	case err != nil:
+		err = fmt.Errorf("can't start import run: %w", err)
+	case run != nil && uc.options.RunID == 0:
+		runCtx, cancel := context.WithCancel(ctx)
+		stop := uc.heartbeat(runCtx, run.ID, cancel)
+		err = uc.execute(runCtx, run, summary)
+		stop()
+		cancel()
+	default:
+		err = uc.execute(ctx, run, summary)
+	}
+	if run != nil {
+		runErr := uc.finishRun(ctx, run, summary, err)
+		switch {
+		case errors.Is(runErr, coreerr.ErrImportRunLost):
+			// Another process runs the import now, its run replaces whatever this execution did.
+			err = runErr
+		case runErr != nil:
+			log.WithContext(ctx).WithError(runErr).WithField("RunId", run.ID).Errorln("can't save import run")
+		}
+	}
+	return summary, err
+}
+
+func (uc *importProductsUseCase) execute(ctx context.Context, run *repositories.ImportRun, summary *services.ImportSummary) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	data, sourceErrs := uc.source.Provide(ctx)
+	if uc.options.Delta {
+		data, sourceErrs = wholeSource(ctx, data, sourceErrs)
+	}
+	seen := make(map[model.ProductId]bool)
+	if uc.options.Sync {
+		data = recordIds(ctx, data, seen)
+	}
+	var progress *checkpoint
+	if run != nil {
+		if run.Offset > 0 {
+			data = skipCommitted(ctx, data, run.Offset)
+		}
+		progress = newCheckpoint(run)
+	}
+	stream := uc.service.Store
+	switch {
+	case uc.options.Delta && uc.options.DryRun:
+		stream = uc.service.PreviewDelta
+	case uc.options.Delta:
+		stream = uc.service.ApplyDelta
+	case uc.options.DryRun:
+		stream = uc.service.Preview
+	}
 	var err error
-	for product := range stream {
-		publishErr := uc.publisher.PublishProductCreated(ctx, services.NewProductCreated(product))
-		if publishErr != nil {
-			err = multierror.Append(err, publishErr)
+	for batch := range stream(ctx, data) {
+		summary.Add(batch)
+		if progress != nil && progress.add(batch) {
+			saveErr := uc.runs.SaveImportRun(ctx, run)
+			switch {
+			case errors.Is(saveErr, coreerr.ErrImportRunLost):
+				log.WithContext(ctx).WithField("RunId", run.ID).Warnln("Import run was taken over by another process")
+				cancel()
+			case saveErr != nil:
+				log.WithContext(ctx).WithError(saveErr).WithField("RunId", run.ID).Warnln("can't checkpoint import run")
+			}
 		}
+		if batch.Err != nil {
+			log.WithContext(ctx).WithError(batch.Err).WithField("Size", len(batch.Products)).Errorln("can't store products batch")
+			err = multierror.Append(err, batch.Err)
+			continue
+		}
+		log.WithContext(ctx).WithFields(log.Fields{
+			"Size":      len(batch.Products),
+			"Invalid":   batch.Rejected(),
+			"Created":   batch.Count(repositories.UpsertCreated),
+			"Restored":  batch.Count(repositories.UpsertRestored),
+			"Updated":   batch.Count(repositories.UpsertUpdated),
+			"Unchanged": batch.Count(repositories.UpsertUnchanged),
+			"Archived":  batch.Archived,
+		}).Infoln("Processed products batch")
+	}
+	if sourceErr := <-sourceErrs; sourceErr != nil {
+		err = multierror.Append(err, fmt.Errorf("can't read products source: %w", sourceErr))
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(err, ctxErr) {
+		// The batches still queued when the import was cancelled were dropped without a result.
+		err = multierror.Append(err, ctxErr)
+	}
+	if err != nil {
+		return err
+	}
+	if rate := summary.ErrorRate(); rate > uc.options.MaxErrorRate {
+		return fmt.Errorf("%w: %.1f%% of %d records, the limit is %.1f%%", coreerr.ErrImportErrorRateExceeded, rate, summary.Records, uc.options.MaxErrorRate)
+	}
+	if !uc.options.Sync || uc.options.DryRun || uc.options.Delta {
+		return nil
 	}
+	archived, err := uc.service.Retire(ctx, seen, uc.options.SyncMaxRemovedPercent)
+	summary.Archived += archived
 	return err
 }
@@ -0,0 +1,318 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/micro-eshop/catalog/pkg/core/dto"
+	coreerr "github.com/micro-eshop/catalog/pkg/core/error"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+	"github.com/micro-eshop/catalog/pkg/core/services"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ImportJobHeartbeatInterval = 15 * time.Second
+	// ImportJobStaleAfter is how long a job or a run may go without heartbeat before another process takes it over.
+	ImportJobStaleAfter = time.Minute
+)
+
+// ImportSourceFactory opens the source of an import job and returns it with the checksum of the file.
+type ImportSourceFactory func(path string, options repositories.ImportJobOptions) (services.ProductsSourceDataProvider, string, error)
+
+// ImportJobs runs imports started through the api in the background. Jobs are import runs with options, their state
+// lives in the database: every process heartbeats the jobs it runs and takes over the jobs of processes that died,
+// so a job survives restarts and resumes from its checkpoint.
+type ImportJobs struct {
+	ctx        context.Context
+	owner      string
+	service    services.CatalogImportService
+	runs       repositories.ImportRunRepository
+	sources    ImportSourceFactory
+	heartbeat  time.Duration
+	staleAfter time.Duration
+	mu         sync.Mutex
+	cancels    map[int64]context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+// newImportRunOwner tells the processes running imports apart, a restarted process is a new owner.
+func newImportRunOwner() string {
+	host, _ := os.Hostname()
+	token := make([]byte, 8)
+	rand.Read(token)
+	return fmt.Sprintf("%s-%x", host, token)
+}
+
+// NewImportJobs runs the jobs until ctx is cancelled, a job stopped that way stays unfinished and is taken over
+// by another process.
+func NewImportJobs(ctx context.Context, service services.CatalogImportService, runs repositories.ImportRunRepository, sources ImportSourceFactory) *ImportJobs {
+	return &ImportJobs{
+		ctx:        ctx,
+		owner:      newImportRunOwner(),
+		service:    service,
+		runs:       runs,
+		sources:    sources,
+		heartbeat:  ImportJobHeartbeatInterval,
+		staleAfter: ImportJobStaleAfter,
+		cancels:    make(map[int64]context.CancelFunc),
+	}
+}
+
+// Submit stores a queued job for the source and starts it.
+func (j *ImportJobs) Submit(ctx context.Context, path string, options repositories.ImportJobOptions) (*repositories.ImportRun, error) {
+	if options.Delta && options.Sync {
+		return nil, fmt.Errorf("%w: delta can't be combined with sync", coreerr.ErrInvalidImportJob)
+	}
+	source, checksum, err := j.sources(path, options)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", coreerr.ErrInvalidImportJob, err.Error())
+	}
+	run := &repositories.ImportRun{SourcePath: path, Checksum: checksum, Status: repositories.ImportRunQueued, Options: &options, Owner: j.owner}
+	if err := j.runs.CreateImportRun(ctx, run); err != nil {
+		return nil, err
+	}
+	j.start(run, source, checksum)
+	return run, nil
+}
+
+// Get returns the job with its progress.
+func (j *ImportJobs) Get(ctx context.Context, id int64) (*repositories.ImportRun, error) {
+	run, err := j.runs.GetImportRun(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if run == nil || run.Options == nil {
+		return nil, coreerr.ErrImportJobNotFound
+	}
+	return run, nil
+}
+
+// Cancel stops the job, wherever it runs. The products it already committed stay imported.
+func (j *ImportJobs) Cancel(ctx context.Context, id int64) (*repositories.ImportRun, error) {
+	if _, err := j.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	requested, err := j.runs.RequestImportRunCancel(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !requested {
+		return nil, coreerr.ErrImportJobFinished
+	}
+	j.mu.Lock()
+	cancel, running := j.cancels[id]
+	j.mu.Unlock()
+	if running {
+		cancel()
+	}
+	run, err := j.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !running && run.Finished() {
+		// A queued job is cancelled right away, no process is going to remove its upload.
+		j.removeUpload(run)
+	}
+	return run, nil
+}
+
+// removeUpload deletes the file uploaded for a finished job.
+func (j *ImportJobs) removeUpload(run *repositories.ImportRun) {
+	if !run.Options.Upload {
+		return
+	}
+	if err := os.Remove(run.SourcePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.WithError(err).WithField("RunId", run.ID).Warnln("can't remove import job upload")
+	}
+}
+
+func (j *ImportJobs) start(run *repositories.ImportRun, source services.ProductsSourceDataProvider, checksum string) {
+	// Jobs outlive the request that started them, they only stop with the process.
+	ctx, cancel := context.WithCancel(j.ctx)
+	j.mu.Lock()
+	if _, ok := j.cancels[run.ID]; ok {
+		j.mu.Unlock()
+		cancel()
+		return
+	}
+	j.cancels[run.ID] = cancel
+	j.mu.Unlock()
+	options := *run.Options
+	uc := NewImportProductsUseCase(j.service, source, j.runs, ImportOptions{
+		Sync:                  options.Sync,
+		Delta:                 options.Delta,
+		SyncMaxRemovedPercent: options.SyncMaxRemovedPercent,
+		MaxErrorRate:          options.MaxErrorRate,
+		SourcePath:            run.SourcePath,
+		Checksum:              checksum,
+		RunID:                 run.ID,
+		Owner:                 j.owner,
+	})
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		defer func() {
+			j.mu.Lock()
+			delete(j.cancels, run.ID)
+			j.mu.Unlock()
+			cancel()
+		}()
+		logger := log.WithContext(ctx).WithField("RunId", run.ID)
+		summary, err := uc.Execute(ctx)
+		if stored, getErr := j.runs.GetImportRun(context.Background(), run.ID); getErr == nil && stored != nil && stored.Finished() {
+			j.removeUpload(stored)
+		}
+		if err != nil {
+			logger.WithError(err).Errorln("import job failed")
+			return
+		}
+		logger.WithFields(log.Fields{
+			"Records": summary.Records,
+			"Created": summary.Created,
+			"Updated": summary.Updated,
+			"Invalid": summary.Invalid,
+		}).Infoln("Import job finished")
+	}()
+}
+
+func (j *ImportJobs) running() []int64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	ids := make([]int64, 0, len(j.cancels))
+	for id := range j.cancels {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// beat keeps the local jobs from being taken over and cancels the ones cancelled through another process
+// or taken over by one.
+func (j *ImportJobs) beat(ctx context.Context) error {
+	running := j.running()
+	cancelRequested, err := j.runs.HeartbeatImportRuns(ctx, j.owner, running...)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, id := range running {
+		requested, owned := cancelRequested[id]
+		if !owned {
+			log.WithContext(ctx).WithField("RunId", id).Warnln("Import job was taken over by another process")
+		}
+		if cancel, ok := j.cancels[id]; ok && (requested || !owned) {
+			cancel()
+		}
+	}
+	return nil
+}
+
+// claim takes over the jobs of processes that stopped heartbeating. A job whose source can't be opened anymore fails.
+func (j *ImportJobs) claim(ctx context.Context) error {
+	runs, err := j.runs.ClaimStaleImportJobs(ctx, j.owner, time.Now().Add(-j.staleAfter))
+	if err != nil {
+		return err
+	}
+	for _, run := range runs {
+		source, checksum, err := j.sources(run.SourcePath, *run.Options)
+		if err != nil {
+			finishedAt := time.Now()
+			run.Status = repositories.ImportRunFailed
+			run.Error = err.Error()
+			run.FinishedAt = &finishedAt
+			if err := j.runs.SaveImportRun(ctx, run); err != nil {
+				return err
+			}
+			j.removeUpload(run)
+			continue
+		}
+		log.WithContext(ctx).WithFields(log.Fields{"RunId": run.ID, "Offset": run.Offset}).Infoln("Taking over import job")
+		j.start(run, source, checksum)
+	}
+	return nil
+}
+
+// Run heartbeats the local jobs and takes over stale ones until ctx is cancelled. Jobs left behind by a process that
+// stopped are picked up by whichever process claims them first once they went stale.
+func (j *ImportJobs) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.heartbeat)
+	defer ticker.Stop()
+	for {
+		if err := j.beat(ctx); err != nil {
+			log.WithContext(ctx).WithError(err).Warnln("can't heartbeat import jobs")
+		}
+		if err := j.claim(ctx); err != nil {
+			log.WithContext(ctx).WithError(err).Warnln("can't claim stale import jobs")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Wait blocks until the jobs started by this process are done, they stop once the context of ImportJobs is cancelled.
+func (j *ImportJobs) Wait() {
+	j.wg.Wait()
+}
+
+type StartImportUseCase struct {
+	jobs *ImportJobs
+}
+
+func NewStartImportUseCase(jobs *ImportJobs) *StartImportUseCase {
+	return &StartImportUseCase{
+		jobs: jobs,
+	}
+}
+
+func (uc *StartImportUseCase) Execute(ctx context.Context, path string, options repositories.ImportJobOptions) (*dto.ImportJobDto, error) {
+	run, err := uc.jobs.Submit(ctx, path, options)
+	if err != nil {
+		return nil, err
+	}
+	return dto.NewImportJobDto(run), nil
+}
+
+type GetImportUseCase struct {
+	jobs *ImportJobs
+}
+
+func NewGetImportUseCase(jobs *ImportJobs) *GetImportUseCase {
+	return &GetImportUseCase{
+		jobs: jobs,
+	}
+}
+
+func (uc *GetImportUseCase) Execute(ctx context.Context, id int64) (*dto.ImportJobDto, error) {
+	run, err := uc.jobs.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return dto.NewImportJobDto(run), nil
+}
+
+type CancelImportUseCase struct {
+	jobs *ImportJobs
+}
+
+func NewCancelImportUseCase(jobs *ImportJobs) *CancelImportUseCase {
+	return &CancelImportUseCase{
+		jobs: jobs,
+	}
+}
+
+func (uc *CancelImportUseCase) Execute(ctx context.Context, id int64) (*dto.ImportJobDto, error) {
+	run, err := uc.jobs.Cancel(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return dto.NewImportJobDto(run), nil
+}
@@ -0,0 +1,313 @@
+package usecase
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	coreerr "github.com/micro-eshop/catalog/pkg/core/error"
+	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+	"github.com/micro-eshop/catalog/pkg/core/services"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeImportRunRepository struct {
+	repositories.ImportRunRepository
+	mu   sync.Mutex
+	runs map[int64]repositories.ImportRun
+}
+
+func (r *fakeImportRunRepository) CreateImportRun(ctx context.Context, run *repositories.ImportRun) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	run.ID = int64(len(r.runs) + 1)
+	run.StartedAt = time.Now()
+	run.UpdatedAt = run.StartedAt
+	r.runs[run.ID] = *run
+	return nil
+}
+
+func (r *fakeImportRunRepository) SaveImportRun(ctx context.Context, run *repositories.ImportRun) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.runs[run.ID].Owner != run.Owner {
+		return coreerr.ErrImportRunLost
+	}
+	run.CancelRequested = r.runs[run.ID].CancelRequested
+	r.runs[run.ID] = *run
+	return nil
+}
+
+func (r *fakeImportRunRepository) GetImportRun(ctx context.Context, id int64) (*repositories.ImportRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	run, ok := r.runs[id]
+	if !ok {
+		return nil, nil
+	}
+	return &run, nil
+}
+
+func (r *fakeImportRunRepository) GetLastImportRun(ctx context.Context, sourcePath string) (*repositories.ImportRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var last *repositories.ImportRun
+	for _, run := range r.runs {
+		if run.SourcePath == sourcePath && (last == nil || run.ID > last.ID) {
+			run := run
+			last = &run
+		}
+	}
+	return last, nil
+}
+
+func (r *fakeImportRunRepository) ClaimImportRun(ctx context.Context, id int64, owner string, staleBefore time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	run := r.runs[id]
+	running := run.Status == repositories.ImportRunQueued || run.Status == repositories.ImportRunRunning
+	if run.Status == repositories.ImportRunCompleted || running && !run.UpdatedAt.Before(staleBefore) {
+		return false, nil
+	}
+	run.Status = repositories.ImportRunRunning
+	run.Owner = owner
+	run.UpdatedAt = time.Now()
+	r.runs[id] = run
+	return true, nil
+}
+
+func (r *fakeImportRunRepository) ClaimStaleImportJobs(ctx context.Context, owner string, staleBefore time.Time) ([]*repositories.ImportRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	claimed := make([]*repositories.ImportRun, 0)
+	for id, run := range r.runs {
+		if run.Options == nil || run.Finished() || run.CancelRequested || !run.UpdatedAt.Before(staleBefore) || run.Owner == owner {
+			continue
+		}
+		run.Owner = owner
+		run.UpdatedAt = time.Now()
+		r.runs[id] = run
+		run := run
+		claimed = append(claimed, &run)
+	}
+	return claimed, nil
+}
+
+func (r *fakeImportRunRepository) HeartbeatImportRuns(ctx context.Context, owner string, ids ...int64) (map[int64]bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cancelRequested := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		run := r.runs[id]
+		if run.Owner != owner {
+			continue
+		}
+		run.UpdatedAt = time.Now()
+		r.runs[id] = run
+		cancelRequested[id] = run.CancelRequested
+	}
+	return cancelRequested, nil
+}
+
+func (r *fakeImportRunRepository) RequestImportRunCancel(ctx context.Context, id int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	run := r.runs[id]
+	if run.Status != repositories.ImportRunQueued && run.Status != repositories.ImportRunRunning {
+		return false, nil
+	}
+	run.CancelRequested = true
+	r.runs[id] = run
+	return true, nil
+}
+
+type fakeImportService struct {
+	services.CatalogImportService
+}
+
+func (s fakeImportService) Store(ctx context.Context, records <-chan *services.SourceRecord) <-chan *services.ImportBatchResult {
+	results := make(chan *services.ImportBatchResult, 1)
+	go func() {
+		defer close(results)
+		batch := &services.ImportBatchResult{Result: repositories.UpsertResult{}}
+		for record := range records {
+			batch.Records++
+			batch.LastLine = record.Line
+			batch.Products = append(batch.Products, record.Product)
+			batch.Result[record.Product.ID] = repositories.UpsertCreated
+		}
+		results <- batch
+	}()
+	return results
+}
+
+func (s fakeImportService) ApplyDelta(ctx context.Context, records <-chan *services.SourceRecord) <-chan *services.ImportBatchResult {
+	return s.Store(ctx, records)
+}
+
+type fakeSource struct {
+	records []*services.SourceRecord
+	err     error
+}
+
+func (s fakeSource) Provide(ctx context.Context) (<-chan *services.SourceRecord, <-chan error) {
+	records := make(chan *services.SourceRecord, len(s.records))
+	errs := make(chan error, 1)
+	for _, record := range s.records {
+		records <- record
+	}
+	if s.err != nil {
+		errs <- s.err
+	}
+	close(records)
+	close(errs)
+	return records, errs
+}
+
+// blockingSource yields its records and then blocks until the import is stopped.
+type blockingSource struct {
+	records []*services.SourceRecord
+}
+
+func (s blockingSource) Provide(ctx context.Context) (<-chan *services.SourceRecord, <-chan error) {
+	records := make(chan *services.SourceRecord, len(s.records))
+	errs := make(chan error, 1)
+	for _, record := range s.records {
+		records <- record
+	}
+	go func() {
+		<-ctx.Done()
+		errs <- ctx.Err()
+		close(records)
+		close(errs)
+	}()
+	return records, errs
+}
+
+func blockingSources(path string, options repositories.ImportJobOptions) (services.ProductsSourceDataProvider, string, error) {
+	record := &services.SourceRecord{Line: 2, Product: model.NewProduct(1, "name", "brand", "description", 1.0)}
+	return blockingSource{records: []*services.SourceRecord{record}}, "checksum", nil
+}
+
+func waitForStatus(t *testing.T, runs *fakeImportRunRepository, id int64, status repositories.ImportRunStatus) {
+	t.Helper()
+	assert.Eventually(t, func() bool {
+		run, _ := runs.GetImportRun(context.Background(), id)
+		return run.Status == status
+	}, time.Second, time.Millisecond)
+}
+
+func TestImportJobs(t *testing.T) {
+	runs := &fakeImportRunRepository{runs: make(map[int64]repositories.ImportRun)}
+	sources := func(path string, options repositories.ImportJobOptions) (services.ProductsSourceDataProvider, string, error) {
+		records := make([]*services.SourceRecord, 0)
+		for i := 1; i <= 3; i++ {
+			records = append(records, &services.SourceRecord{Line: i + 1, Product: model.NewProduct(model.ProductId(i), "name", "brand", "description", 1.0)})
+		}
+		return fakeSource{records: records}, "checksum", nil
+	}
+	ctx := context.Background()
+	jobs := NewImportJobs(ctx, fakeImportService{}, runs, sources)
+
+	run, err := jobs.Submit(ctx, "/imports/products.csv", repositories.ImportJobOptions{MaxErrorRate: DefaultMaxErrorRate})
+	assert.Nil(t, err)
+	jobs.Wait()
+
+	job, err := jobs.Get(ctx, run.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, repositories.ImportRunCompleted, job.Status)
+	assert.Equal(t, 3, job.Records)
+	assert.Equal(t, 3, job.Created)
+	assert.Equal(t, 4, job.Offset)
+	assert.NotNil(t, job.FinishedAt)
+
+	_, err = jobs.Cancel(ctx, run.ID)
+	assert.ErrorIs(t, err, coreerr.ErrImportJobFinished)
+
+	cli := &repositories.ImportRun{SourcePath: "products.csv", Status: repositories.ImportRunRunning}
+	assert.Nil(t, runs.CreateImportRun(ctx, cli))
+	_, err = jobs.Get(ctx, cli.ID)
+	assert.ErrorIs(t, err, coreerr.ErrImportJobNotFound)
+}
+
+func TestImportJobsCancel(t *testing.T) {
+	runs := &fakeImportRunRepository{runs: make(map[int64]repositories.ImportRun)}
+	ctx := context.Background()
+	jobs := NewImportJobs(ctx, fakeImportService{}, runs, blockingSources)
+	upload := filepath.Join(t.TempDir(), "upload.csv")
+	assert.Nil(t, os.WriteFile(upload, []byte("id"), 0o600))
+
+	run, err := jobs.Submit(ctx, upload, repositories.ImportJobOptions{MaxErrorRate: DefaultMaxErrorRate, Upload: true})
+	assert.Nil(t, err)
+	waitForStatus(t, runs, run.ID, repositories.ImportRunRunning)
+
+	_, err = jobs.Cancel(ctx, run.ID)
+	assert.Nil(t, err)
+	jobs.Wait()
+
+	job, err := jobs.Get(ctx, run.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, repositories.ImportRunCancelled, job.Status)
+	assert.NotNil(t, job.FinishedAt)
+	assert.NoFileExists(t, upload)
+}
+
+func TestImportJobsClaim(t *testing.T) {
+	runs := &fakeImportRunRepository{runs: make(map[int64]repositories.ImportRun)}
+	firstCtx, stopFirst := context.WithCancel(context.Background())
+	first := NewImportJobs(firstCtx, fakeImportService{}, runs, blockingSources)
+	first.staleAfter = -time.Minute
+	upload := filepath.Join(t.TempDir(), "upload.csv")
+	assert.Nil(t, os.WriteFile(upload, []byte("id"), 0o600))
+
+	run, err := first.Submit(firstCtx, upload, repositories.ImportJobOptions{MaxErrorRate: DefaultMaxErrorRate, Upload: true})
+	assert.Nil(t, err)
+	waitForStatus(t, runs, run.ID, repositories.ImportRunRunning)
+	assert.Nil(t, first.claim(firstCtx))
+	assert.ElementsMatch(t, []int64{run.ID}, first.running())
+
+	stopFirst()
+	first.Wait()
+	job, _ := runs.GetImportRun(context.Background(), run.ID)
+	assert.Equal(t, repositories.ImportRunRunning, job.Status)
+	assert.Nil(t, job.FinishedAt)
+	assert.FileExists(t, upload)
+
+	secondCtx, stopSecond := context.WithCancel(context.Background())
+	second := NewImportJobs(secondCtx, fakeImportService{}, runs, blockingSources)
+	second.staleAfter = -time.Minute
+	assert.Nil(t, second.claim(secondCtx))
+	assert.ElementsMatch(t, []int64{run.ID}, second.running())
+	job, _ = runs.GetImportRun(context.Background(), run.ID)
+	assert.Equal(t, second.owner, job.Owner)
+	stopSecond()
+	second.Wait()
+}
+
+func TestImportJobsWhenTakenOver(t *testing.T) {
+	runs := &fakeImportRunRepository{runs: make(map[int64]repositories.ImportRun)}
+	ctx := context.Background()
+	jobs := NewImportJobs(ctx, fakeImportService{}, runs, blockingSources)
+
+	run, err := jobs.Submit(ctx, "/imports/products.csv", repositories.ImportJobOptions{MaxErrorRate: DefaultMaxErrorRate})
+	assert.Nil(t, err)
+	waitForStatus(t, runs, run.ID, repositories.ImportRunRunning)
+	runs.mu.Lock()
+	taken := runs.runs[run.ID]
+	taken.Owner = "another process"
+	runs.runs[run.ID] = taken
+	runs.mu.Unlock()
+
+	assert.Nil(t, jobs.beat(ctx))
+	jobs.Wait()
+
+	job, _ := runs.GetImportRun(ctx, run.ID)
+	assert.Equal(t, "another process", job.Owner)
+	assert.Equal(t, repositories.ImportRunRunning, job.Status)
+	assert.Nil(t, job.FinishedAt)
+	assert.Empty(t, jobs.running())
+}
@@ -0,0 +1,81 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+	"github.com/micro-eshop/catalog/pkg/core/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpoint(t *testing.T) {
+	run := &repositories.ImportRun{Offset: 10, Records: 9}
+	progress := newCheckpoint(run)
+	batch := func(seq, lastLine int, err error) *services.ImportBatchResult {
+		return &services.ImportBatchResult{Seq: seq, LastLine: lastLine, Records: 2, Result: repositories.UpsertResult{1: repositories.UpsertCreated}, Err: err}
+	}
+
+	rejected := batch(1, 14, nil)
+	rejected.Errors = []services.RowError{{Line: 13, Column: "ProductId"}, {Line: 13, Column: "Price"}}
+	assert.False(t, progress.add(rejected))
+	assert.Equal(t, 10, run.Offset)
+
+	assert.True(t, progress.add(batch(0, 12, nil)))
+	assert.Equal(t, 14, run.Offset)
+	assert.Equal(t, 13, run.Records)
+	assert.Equal(t, 2, run.Created)
+	assert.Equal(t, 1, run.Invalid)
+	assert.Equal(t, rejected.Errors, run.RowErrors)
+
+	assert.False(t, progress.add(batch(2, 16, errors.New("connection reset"))))
+	assert.False(t, progress.add(batch(3, 18, nil)))
+	assert.Equal(t, 14, run.Offset)
+}
+
+func TestResumeWhenRunIsStillRunning(t *testing.T) {
+	ctx := context.Background()
+	runs := &fakeImportRunRepository{runs: map[int64]repositories.ImportRun{
+		1: {ID: 1, SourcePath: "/seed/products.csv", Checksum: "checksum", Status: repositories.ImportRunRunning, Offset: 2, UpdatedAt: time.Now()},
+	}}
+	records := []*services.SourceRecord{{Line: 2, Product: model.NewProduct(model.ProductId(1), "name", "brand", "description", 1.0)}, {Line: 3, Product: model.NewProduct(model.ProductId(2), "name", "brand", "description", 1.0)}}
+	options := ImportOptions{SourcePath: "/seed/products.csv", Checksum: "checksum", Resume: true, MaxErrorRate: DefaultMaxErrorRate}
+
+	_, err := NewImportProductsUseCase(fakeImportService{}, fakeSource{records: records}, runs, options).Execute(ctx)
+	assert.EqualError(t, err, "can't start import run: import run 1 is still running")
+	assert.Equal(t, repositories.ImportRunRunning, runs.runs[1].Status)
+
+	stale := runs.runs[1]
+	stale.UpdatedAt = time.Now().Add(-2 * ImportJobStaleAfter)
+	runs.runs[1] = stale
+	summary, err := NewImportProductsUseCase(fakeImportService{}, fakeSource{records: records}, runs, options).Execute(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, summary.Records)
+	assert.Equal(t, repositories.ImportRunCompleted, runs.runs[1].Status)
+	assert.Equal(t, 3, runs.runs[1].Offset)
+}
+
+func TestDeltaWhenSourceFails(t *testing.T) {
+	records := []*services.SourceRecord{{Line: 2, Op: services.DeltaUpsert, Product: model.NewProduct(model.ProductId(1), "name", "brand", "description", 1.0)}}
+	source := fakeSource{records: records, err: errors.New("unexpected EOF")}
+
+	summary, err := NewImportProductsUseCase(fakeImportService{}, source, nil, ImportOptions{Delta: true, MaxErrorRate: DefaultMaxErrorRate}).Execute(context.Background())
+	assert.ErrorContains(t, err, "can't read products source: unexpected EOF")
+	assert.Equal(t, 0, summary.Records)
+	assert.Equal(t, 0, summary.Created)
+}
+
+func TestImportWhenCancelledAfterSourceWasRead(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	runs := &fakeImportRunRepository{runs: make(map[int64]repositories.ImportRun)}
+	records := []*services.SourceRecord{{Line: 2, Product: model.NewProduct(model.ProductId(1), "name", "brand", "description", 1.0)}}
+	options := ImportOptions{SourcePath: "/seed/products.csv", Checksum: "checksum", MaxErrorRate: DefaultMaxErrorRate}
+
+	_, err := NewImportProductsUseCase(fakeImportService{}, fakeSource{records: records}, runs, options).Execute(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, repositories.ImportRunCancelled, runs.runs[1].Status)
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/micro-eshop/catalog/pkg/core/dto"
+	coreerr "github.com/micro-eshop/catalog/pkg/core/error"
+	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/usecase"
+)
+
+// RequireAdminToken lets through only the requests carrying token as their bearer token.
+func RequireAdminToken(token string) gin.HandlerFunc {
+	expected := []byte("Bearer " + token)
+	return func(c *gin.Context) {
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), expected) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"message": "unauthorized",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+type CatalogAdminHandler struct {
+	createProductUseCase *usecase.CreateProductUseCase
+	updateProductUseCase *usecase.UpdateProductUseCase
+	patchProductUseCase  *usecase.PatchProductUseCase
+	deleteProductUseCase *usecase.DeleteProductUseCase
+}
+
+func NewCatalogAdminHandler(createProductUseCase *usecase.CreateProductUseCase, updateProductUseCase *usecase.UpdateProductUseCase, patchProductUseCase *usecase.PatchProductUseCase, deleteProductUseCase *usecase.DeleteProductUseCase) *CatalogAdminHandler {
+	return &CatalogAdminHandler{
+		createProductUseCase: createProductUseCase,
+		updateProductUseCase: updateProductUseCase,
+		patchProductUseCase:  patchProductUseCase,
+		deleteProductUseCase: deleteProductUseCase,
+	}
+}
+
+func productIdParam(c *gin.Context) (model.ProductId, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": "id is not a number",
+		})
+		return 0, false
+	}
+	return model.ProductId(id), true
+}
+
+func writeProductError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, coreerr.ErrInvalidProduct):
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	case errors.Is(err, coreerr.ErrProductNotFound):
+		c.JSON(404, gin.H{
+			"message": "product not found",
+		})
+	case errors.Is(err, coreerr.ErrProductAlreadyExists):
+		c.JSON(409, gin.H{
+			"message": "product already exists",
+		})
+	default:
+		c.Error(err)
+		c.String(http.StatusInternalServerError, "unknown error")
+	}
+}
+
+func (handler *CatalogAdminHandler) createProduct(c *gin.Context) {
+	var product dto.ProductDto
+	if err := c.ShouldBindJSON(&product); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	result, err := handler.createProductUseCase.Execute(c.Request.Context(), &product)
+	if err != nil {
+		writeProductError(c, err)
+		return
+	}
+	c.Header("Location", fmt.Sprintf("/catalog/products/%d", result.ID))
+	c.JSON(201, result)
+}
+
+func (handler *CatalogAdminHandler) updateProduct(c *gin.Context) {
+	id, ok := productIdParam(c)
+	if !ok {
+		return
+	}
+	var product dto.ProductDto
+	if err := c.ShouldBindJSON(&product); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	result, err := handler.updateProductUseCase.Execute(c.Request.Context(), id, &product)
+	if err != nil {
+		writeProductError(c, err)
+		return
+	}
+	c.JSON(200, result)
+}
+
+func (handler *CatalogAdminHandler) patchProduct(c *gin.Context) {
+	id, ok := productIdParam(c)
+	if !ok {
+		return
+	}
+	switch c.ContentType() {
+	case "application/merge-patch+json", "application/json":
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"message": "patch must be sent as application/merge-patch+json",
+		})
+		return
+	}
+	patch, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	result, err := handler.patchProductUseCase.Execute(c.Request.Context(), id, patch)
+	if err != nil {
+		writeProductError(c, err)
+		return
+	}
+	c.JSON(200, result)
+}
+
+func (handler *CatalogAdminHandler) deleteProduct(c *gin.Context) {
+	id, ok := productIdParam(c)
+	if !ok {
+		return
+	}
+
+	if err := handler.deleteProductUseCase.Execute(c.Request.Context(), id); err != nil {
+		writeProductError(c, err)
+		return
+	}
+	c.Status(204)
+}
+
+func (h *CatalogAdminHandler) Setup(r gin.IRouter) {
+	r.Group("/catalog").
+		POST("/products", h.createProduct).
+		PUT("/products/:id", h.updateProduct).
+		PATCH("/products/:id", h.patchProduct).
+		DELETE("/products/:id", h.deleteProduct)
+}
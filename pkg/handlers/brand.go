@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/micro-eshop/catalog/pkg/core/usecase"
+)
+
+type BrandHandler struct {
+	getBrandsUseCase      *usecase.GetBrandsUseCase
+	getBrandBySlugUseCase *usecase.GetBrandBySlugUseCase
+}
+
+func NewBrandHandler(getBrandsUseCase *usecase.GetBrandsUseCase, getBrandBySlugUseCase *usecase.GetBrandBySlugUseCase) *BrandHandler {
+	return &BrandHandler{
+		getBrandsUseCase:      getBrandsUseCase,
+		getBrandBySlugUseCase: getBrandBySlugUseCase,
+	}
+}
+
+func (handler *BrandHandler) getBrands(c *gin.Context) {
+	result, err := handler.getBrandsUseCase.Execute(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		c.String(http.StatusInternalServerError, "unknown error")
+		return
+	}
+	c.JSON(200, result)
+}
+
+func (handler *BrandHandler) getBrandBySlug(c *gin.Context) {
+	brand, err := handler.getBrandBySlugUseCase.Execute(c.Request.Context(), c.Param("slug"))
+	if err != nil {
+		c.AbortWithError(500, err)
+		return
+	}
+
+	if brand == nil {
+		c.JSON(404, gin.H{
+			"message": "brand not found",
+		})
+		return
+	}
+	c.JSON(200, brand)
+}
+
+func (h *BrandHandler) Setup(r gin.IRouter) {
+	r.Group("/catalog").
+		GET("/brands", h.getBrands).
+		GET("/brands/:slug", h.getBrandBySlug)
+}
@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	coreerr "github.com/micro-eshop/catalog/pkg/core/error"
 	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
 	"github.com/micro-eshop/catalog/pkg/core/usecase"
 )
 
@@ -18,15 +21,55 @@ func parseInt(idsStr []string) []model.ProductId {
 	return res
 }
 
+type searchProductsRequest struct {
+	Query         string   `form:"q"`
+	Name          string   `form:"name"`
+	Brand         string   `form:"brand"`
+	Brands        []string `form:"brands"`
+	PriceFrom     float64  `form:"priceFrom" binding:"gte=0"`
+	PriceTo       float64  `form:"priceTo" binding:"gte=0"`
+	InPromotion   bool     `form:"inPromotion"`
+	PageSize      int      `form:"pageSize" binding:"gte=0"`
+	Cursor        string   `form:"cursor"`
+	SortBy        string   `form:"sortBy" binding:"omitempty,oneof=id name brand price relevance"`
+	SortDirection string   `form:"sortDirection" binding:"omitempty,oneof=asc desc"`
+	Facets        bool     `form:"facets"`
+}
+
+func (r searchProductsRequest) toSearchParams() repositories.ProductSearchParams {
+	return repositories.ProductSearchParams{
+		Query:         r.Query,
+		Name:          r.Name,
+		Brand:         r.Brand,
+		Brands:        r.Brands,
+		PriceFrom:     r.PriceFrom,
+		PriceTo:       r.PriceTo,
+		InPromotion:   r.InPromotion,
+		PageSize:      r.PageSize,
+		Cursor:        r.Cursor,
+		SortBy:        repositories.SortField(r.SortBy),
+		SortDirection: repositories.SortDirection(r.SortDirection),
+	}
+}
+
+type suggestRequest struct {
+	Query string `form:"q" binding:"required"`
+	Limit int    `form:"limit" binding:"gte=0"`
+}
+
 type CatalogHandler struct {
 	getProductByIdUseCase  *usecase.GetProductByIdUseCase
 	getProductByIdsUseCase *usecase.GetProductByIdsUseCase
+	searchProductsUseCase  *usecase.SearchProductsUseCase
+	suggestProductsUseCase *usecase.SuggestProductsUseCase
 }
 
-func NewCatalogHandler(getProductByIdUseCase *usecase.GetProductByIdUseCase, getProductByIdsUseCase *usecase.GetProductByIdsUseCase) *CatalogHandler {
+func NewCatalogHandler(getProductByIdUseCase *usecase.GetProductByIdUseCase, getProductByIdsUseCase *usecase.GetProductByIdsUseCase, searchProductsUseCase *usecase.SearchProductsUseCase, suggestProductsUseCase *usecase.SuggestProductsUseCase) *CatalogHandler {
 	return &CatalogHandler{
 		getProductByIdUseCase:  getProductByIdUseCase,
 		getProductByIdsUseCase: getProductByIdsUseCase,
+		searchProductsUseCase:  searchProductsUseCase,
+		suggestProductsUseCase: suggestProductsUseCase,
 	}
 }
 
@@ -66,6 +109,13 @@ func (handler *CatalogHandler) getProductByIds(c *gin.Context) {
 
 	result, err := handler.getProductByIdsUseCase.Execute(c.Request.Context(), ids)
 
+	if errors.Is(err, coreerr.ErrTooManyProductIds) {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
 	if err != nil {
 		c.Error(err)
 		c.String(http.StatusInternalServerError, "unknown error")
@@ -80,6 +130,65 @@ func (handler *CatalogHandler) getProductByIds(c *gin.Context) {
 	c.JSON(200, result)
 }
 
+func (handler *CatalogHandler) searchProducts(c *gin.Context) {
+	var req searchProductsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	result, err := handler.searchProductsUseCase.Execute(c.Request.Context(), req.toSearchParams(), req.Facets)
+
+	if errors.Is(err, coreerr.ErrInvalidSearchParams) {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err != nil {
+		c.Error(err)
+		c.String(http.StatusInternalServerError, "unknown error")
+		return
+	}
+
+	c.JSON(200, result)
+}
+
+func (handler *CatalogHandler) suggest(c *gin.Context) {
+	var req suggestRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	result, err := handler.suggestProductsUseCase.Execute(c.Request.Context(), req.Query, req.Limit)
+
+	if errors.Is(err, coreerr.ErrInvalidSearchParams) {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err != nil {
+		c.Error(err)
+		c.String(http.StatusInternalServerError, "unknown error")
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=60")
+	c.JSON(200, result)
+}
+
 func (h *CatalogHandler) Setup(r gin.IRouter) {
-	r.Group("/catalog").GET("/products/:id", h.getProductById).GET("/products", h.getProductByIds)
+	r.Group("/catalog").
+		GET("/suggest", h.suggest).
+		GET("/products/search", h.searchProducts).
+		GET("/products/:id", h.getProductById).
+		GET("/products", h.getProductByIds)
 }
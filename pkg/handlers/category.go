@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	coreerr "github.com/micro-eshop/catalog/pkg/core/error"
+	"github.com/micro-eshop/catalog/pkg/core/model"
+	"github.com/micro-eshop/catalog/pkg/core/usecase"
+)
+
+type CategoryHandler struct {
+	getCategoryTreeUseCase     *usecase.GetCategoryTreeUseCase
+	getCategoryProductsUseCase *usecase.GetCategoryProductsUseCase
+}
+
+func NewCategoryHandler(getCategoryTreeUseCase *usecase.GetCategoryTreeUseCase, getCategoryProductsUseCase *usecase.GetCategoryProductsUseCase) *CategoryHandler {
+	return &CategoryHandler{
+		getCategoryTreeUseCase:     getCategoryTreeUseCase,
+		getCategoryProductsUseCase: getCategoryProductsUseCase,
+	}
+}
+
+func (handler *CategoryHandler) getCategoryTree(c *gin.Context) {
+	result, err := handler.getCategoryTreeUseCase.Execute(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		c.String(http.StatusInternalServerError, "unknown error")
+		return
+	}
+	c.JSON(200, result)
+}
+
+func (handler *CategoryHandler) getCategoryProducts(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || model.ValidateCategoryId(model.CategoryId(id)) != nil {
+		c.JSON(400, gin.H{
+			"message": "id is not a valid category id",
+		})
+		return
+	}
+
+	var req searchProductsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	result, err := handler.getCategoryProductsUseCase.Execute(c.Request.Context(), model.CategoryId(id), req.toSearchParams(), req.Facets)
+
+	if errors.Is(err, coreerr.ErrCategoryNotFound) {
+		c.JSON(404, gin.H{
+			"message": "category not found",
+		})
+		return
+	}
+
+	if errors.Is(err, coreerr.ErrInvalidSearchParams) {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err != nil {
+		c.Error(err)
+		c.String(http.StatusInternalServerError, "unknown error")
+		return
+	}
+
+	c.JSON(200, result)
+}
+
+func (h *CategoryHandler) Setup(r gin.IRouter) {
+	r.Group("/catalog").
+		GET("/categories", h.getCategoryTree).
+		GET("/categories/:id/products", h.getCategoryProducts)
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	coreerr "github.com/micro-eshop/catalog/pkg/core/error"
+	"github.com/micro-eshop/catalog/pkg/core/repositories"
+	"github.com/micro-eshop/catalog/pkg/core/usecase"
+)
+
+type startImportRequest struct {
+	// Path is a file on the shared imports volume, relative to it. It is ignored when a file is uploaded.
+	Path                  string   `form:"path" json:"path"`
+	Format                string   `form:"format" json:"format"`
+	Columns               string   `form:"columns" json:"columns"`
+	Sync                  bool     `form:"sync" json:"sync"`
+	Delta                 bool     `form:"delta" json:"delta"`
+	SyncMaxRemovedPercent *float64 `form:"syncMaxRemovedPercent" json:"syncMaxRemovedPercent"`
+	MaxErrorRate          *float64 `form:"maxErrorRate" json:"maxErrorRate"`
+}
+
+// options uses the limits of the request only when they are stricter than the defaults of the server.
+func (r startImportRequest) options() repositories.ImportJobOptions {
+	options := repositories.ImportJobOptions{
+		Format:                r.Format,
+		Columns:               r.Columns,
+		Sync:                  r.Sync,
+		Delta:                 r.Delta,
+		SyncMaxRemovedPercent: usecase.DefaultSyncMaxRemovedPercent,
+		MaxErrorRate:          usecase.DefaultMaxErrorRate,
+	}
+	if r.SyncMaxRemovedPercent != nil && *r.SyncMaxRemovedPercent < options.SyncMaxRemovedPercent {
+		options.SyncMaxRemovedPercent = *r.SyncMaxRemovedPercent
+	}
+	if r.MaxErrorRate != nil && *r.MaxErrorRate < options.MaxErrorRate {
+		options.MaxErrorRate = *r.MaxErrorRate
+	}
+	return options
+}
+
+type ImportHandler struct {
+	importsDir          string
+	startImportUseCase  *usecase.StartImportUseCase
+	getImportUseCase    *usecase.GetImportUseCase
+	cancelImportUseCase *usecase.CancelImportUseCase
+}
+
+// NewImportHandler keeps uploaded files in importsDir, it has to be an absolute path on a volume shared by all api
+// instances so an interrupted job can be resumed by any of them.
+func NewImportHandler(importsDir string, startImportUseCase *usecase.StartImportUseCase, getImportUseCase *usecase.GetImportUseCase, cancelImportUseCase *usecase.CancelImportUseCase) *ImportHandler {
+	return &ImportHandler{
+		importsDir:          importsDir,
+		startImportUseCase:  startImportUseCase,
+		getImportUseCase:    getImportUseCase,
+		cancelImportUseCase: cancelImportUseCase,
+	}
+}
+
+// importPath resolves path inside dir and rejects paths that would leave it.
+func importPath(dir, path string) (string, error) {
+	if path == "" {
+		return "", errors.New("either a file or a path is required")
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	path = filepath.Clean(path)
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.New("path has to be a file inside the imports directory")
+	}
+	return path, nil
+}
+
+// saveUpload stores the uploaded file in dir, it keeps the extension so the format can be detected from it.
+func saveUpload(dir string, header *multipart.FileHeader) (string, error) {
+	upload, err := header.Open()
+	if err != nil {
+		return "", err
+	}
+	defer upload.Close()
+	file, err := os.CreateTemp(dir, "upload-*"+filepath.Ext(filepath.Base(header.Filename)))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := io.Copy(file, upload); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+func importIdParam(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": "id is not a number",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+func writeImportError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, coreerr.ErrInvalidImportJob):
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	case errors.Is(err, coreerr.ErrImportJobNotFound):
+		c.JSON(404, gin.H{
+			"message": "import job not found",
+		})
+	case errors.Is(err, coreerr.ErrImportJobFinished):
+		c.JSON(409, gin.H{
+			"message": "import job already finished",
+		})
+	default:
+		c.Error(err)
+		c.String(http.StatusInternalServerError, "unknown error")
+	}
+}
+
+func (handler *ImportHandler) startImport(c *gin.Context) {
+	var request startImportRequest
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var path string
+	uploaded := false
+	if header, err := c.FormFile("file"); err == nil {
+		path, err = saveUpload(handler.importsDir, header)
+		if err != nil {
+			c.Error(err)
+			c.String(http.StatusInternalServerError, "unknown error")
+			return
+		}
+		uploaded = true
+	} else {
+		path, err = importPath(handler.importsDir, request.Path)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+
+	options := request.options()
+	options.Upload = uploaded
+	result, err := handler.startImportUseCase.Execute(c.Request.Context(), path, options)
+	if err != nil {
+		if uploaded {
+			os.Remove(path)
+		}
+		writeImportError(c, err)
+		return
+	}
+	c.Header("Location", fmt.Sprintf("/catalog/admin/imports/%d", result.ID))
+	c.JSON(http.StatusAccepted, result)
+}
+
+func (handler *ImportHandler) getImport(c *gin.Context) {
+	id, ok := importIdParam(c)
+	if !ok {
+		return
+	}
+
+	result, err := handler.getImportUseCase.Execute(c.Request.Context(), id)
+	if err != nil {
+		writeImportError(c, err)
+		return
+	}
+	c.JSON(200, result)
+}
+
+func (handler *ImportHandler) cancelImport(c *gin.Context) {
+	id, ok := importIdParam(c)
+	if !ok {
+		return
+	}
+
+	result, err := handler.cancelImportUseCase.Execute(c.Request.Context(), id)
+	if err != nil {
+		writeImportError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, result)
+}
+
+func (h *ImportHandler) Setup(r gin.IRouter) {
+	r.Group("/catalog/admin").
+		POST("/imports", h.startImport).
+		GET("/imports/:id", h.getImport).
+		DELETE("/imports/:id", h.cancelImport)
+}